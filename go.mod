@@ -2,10 +2,14 @@ module github.com/genesisdb-io/genesisdb-io-client-go
 
 go 1.22
 
-require github.com/cloudevents/sdk-go/v2 v2.16.0
+require (
+	github.com/cloudevents/sdk-go/v2 v2.16.0
+	github.com/google/uuid v1.6.0
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+)
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
@@ -0,0 +1,246 @@
+// Package aggregate loads and saves event-sourced aggregates stored in
+// GenesisDB.
+//
+// A Repository rebuilds the state of an aggregate from the events of its
+// subject by applying them to handlers registered per event type. New events
+// raised on the aggregate are applied the same way and committed on Save,
+// guarded by a precondition that no other event was stored for the subject
+// since it was loaded: the subject must still have exactly as many events as
+// the aggregate has seen.
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/google/uuid"
+)
+
+// ErrConcurrencyConflict matches a *ConflictError.
+var ErrConcurrencyConflict = errors.New("concurrency conflict")
+
+// ErrNoHandler is returned for events without a handler when the repository
+// requires handlers for every event type.
+var ErrNoHandler = errors.New("no handler for event type")
+
+// ConflictError is returned by Save when events were stored for the subject
+// after the aggregate was loaded. Load the aggregate again and retry.
+type ConflictError struct {
+	Subject string
+	// LastEventID is the ID of the last event the aggregate had seen, empty
+	// for an aggregate without events.
+	LastEventID string
+	Err         error
+}
+
+func (e *ConflictError) Error() string {
+	if e.LastEventID == "" {
+		return fmt.Sprintf("concurrency conflict: %s already has events", e.Subject)
+	}
+	return fmt.Sprintf("concurrency conflict: %s has events after %s", e.Subject, e.LastEventID)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConcurrencyConflict
+}
+
+// Handler applies an event to the state of an aggregate.
+type Handler[A any] func(state *A, event genesisdb.Event) error
+
+// Aggregate is the state of a subject rebuilt from its events, together with
+// the events raised since it was loaded.
+type Aggregate[A any] struct {
+	Subject string
+	State   A
+	// Version is the number of events applied, including raised ones. Save
+	// compares it with the number of stored events of the subject, so
+	// upcasters must not split or drop the events of aggregates.
+	Version int
+	// LastEventID is the ID of the last stored event of the subject as of
+	// the last Load or Save, empty if there was none.
+	LastEventID string
+
+	pending         []genesisdb.Event
+	snapshotVersion int
+	snapshotTime    time.Time
+}
+
+// Pending returns the events raised since the aggregate was loaded or last
+// saved.
+func (a *Aggregate[A]) Pending() []genesisdb.Event {
+	return a.pending
+}
+
+// Repository loads and saves aggregates of type A.
+type Repository[A any] struct {
+	// Initial returns the state of an aggregate without events. If nil, the
+	// zero value of A is used.
+	Initial func() A
+	// RequireHandlers fails with ErrNoHandler on events without a handler
+	// instead of ignoring them.
+	RequireHandlers bool
+
+	// Snapshots stores snapshots of aggregates, which Load resumes from. If
+	// nil, aggregates are always rebuilt from all their events.
+	Snapshots SnapshotStore
+	// SnapshotPolicy decides after every Load and Save whether to store a
+	// snapshot. If nil, snapshots are only stored by calling Snapshot.
+	SnapshotPolicy SnapshotPolicy
+	// OnSnapshotError is called when storing a snapshot on behalf of
+	// SnapshotPolicy fails. Load and Save succeed regardless.
+	OnSnapshotError func(error)
+
+	client   *genesisdb.Genesisdb
+	source   string
+	handlers map[string]Handler[A]
+}
+
+// NewRepository creates a repository that commits raised events with the
+// given source.
+func NewRepository[A any](client *genesisdb.Genesisdb, source string) *Repository[A] {
+	return &Repository[A]{
+		client:   client,
+		source:   source,
+		handlers: make(map[string]Handler[A]),
+	}
+}
+
+// On registers the handler for events of eventType.
+func (r *Repository[A]) On(eventType string, handler Handler[A]) *Repository[A] {
+	r.handlers[eventType] = handler
+	return r
+}
+
+// Handle registers a handler for events of eventType that receives their
+// data decoded into E.
+func Handle[A, E any](r *Repository[A], eventType string, handler func(state *A, data E) error) {
+	r.On(eventType, func(state *A, event genesisdb.Event) error {
+		if data, ok := event.Data.(E); ok {
+			return handler(state, data)
+		}
+		data, err := genesisdb.DecodeData[E](event)
+		if err != nil {
+			return err
+		}
+		return handler(state, data)
+	})
+}
+
+// New returns an aggregate for subject without any events. Saving it fails
+// with a *ConflictError if the subject already has events.
+func (r *Repository[A]) New(subject string) *Aggregate[A] {
+	agg := &Aggregate[A]{Subject: subject}
+	if r.Initial != nil {
+		agg.State = r.Initial()
+	}
+	return agg
+}
+
+// Load rebuilds the aggregate of subject from its latest snapshot, if any,
+// and the events after it. Events of subjects below subject are not part of
+// the aggregate.
+func (r *Repository[A]) Load(ctx context.Context, subject string) (*Aggregate[A], error) {
+	agg := r.New(subject)
+	options, err := r.restore(ctx, agg)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := r.client.StreamEventsContext(ctx, subject, options)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if event.Subject != subject {
+			continue
+		}
+		if err := r.apply(agg, event); err != nil {
+			return nil, err
+		}
+		agg.LastEventID = event.ID
+	}
+
+	r.maybeSnapshot(ctx, agg)
+	return agg, nil
+}
+
+// Raise applies a new event of eventType with data to the aggregate and
+// keeps it for the next Save.
+func (r *Repository[A]) Raise(agg *Aggregate[A], eventType string, data interface{}) error {
+	event := genesisdb.Event{
+		ID:      uuid.New().String(),
+		Source:  r.source,
+		Subject: agg.Subject,
+		Type:    eventType,
+		Data:    data,
+	}
+	if err := r.apply(agg, event); err != nil {
+		return err
+	}
+	agg.pending = append(agg.pending, event)
+	return nil
+}
+
+// Save commits the pending events of the aggregate. It fails with a
+// *ConflictError if other events were stored for the subject since the
+// aggregate was loaded; the aggregate is left unchanged then.
+func (r *Repository[A]) Save(ctx context.Context, agg *Aggregate[A]) error {
+	if len(agg.pending) == 0 {
+		return nil
+	}
+
+	precondition, err := r.unchangedSince(agg)
+	if err != nil {
+		return err
+	}
+
+	err = r.client.CommitEventsWithPreconditionsContext(ctx, agg.pending, []genesisdb.Precondition{precondition})
+	if errors.Is(err, genesisdb.ErrPreconditionFailed) {
+		return &ConflictError{Subject: agg.Subject, LastEventID: agg.LastEventID, Err: err}
+	}
+	if err != nil {
+		return err
+	}
+
+	agg.LastEventID = agg.pending[len(agg.pending)-1].ID
+	agg.pending = nil
+
+	r.maybeSnapshot(ctx, agg)
+	return nil
+}
+
+// unchangedSince returns the precondition that the subject still has as
+// many events as the aggregate has seen. Counting is used rather than
+// comparing the last event, because the order by time is ambiguous for the
+// events of one commit, which share their time.
+func (r *Repository[A]) unchangedSince(agg *Aggregate[A]) (genesisdb.Precondition, error) {
+	stored := agg.Version - len(agg.pending)
+	if stored == 0 {
+		return genesisdb.IsSubjectNew(agg.Subject), nil
+	}
+	return genesisdb.IsQueryResultTrueWithParams(
+		"STREAM e FROM events WHERE e.subject == $subject MAP COUNT() == $version",
+		genesisdb.Params{"subject": agg.Subject, "version": stored},
+	)
+}
+
+func (r *Repository[A]) apply(agg *Aggregate[A], event genesisdb.Event) error {
+	handler, ok := r.handlers[event.Type]
+	if !ok {
+		if r.RequireHandlers {
+			return fmt.Errorf("%w: %s", ErrNoHandler, event.Type)
+		}
+	} else if err := handler(&agg.State, event); err != nil {
+		return fmt.Errorf("error applying event %s of type %s: %w", event.ID, event.Type, err)
+	}
+	agg.Version++
+	return nil
+}
@@ -0,0 +1,149 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdbtest"
+)
+
+type account struct {
+	Owner   string
+	Balance int
+}
+
+type deposited struct {
+	Amount int `json:"amount"`
+}
+
+func newAccounts(t *testing.T, server *genesisdbtest.Server) *Repository[account] {
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	accounts := NewRepository[account](client, "io.genesisdb.bank")
+	accounts.On("account-opened", func(state *account, event genesisdb.Event) error {
+		state.Owner = event.Data.(map[string]interface{})["owner"].(string)
+		return nil
+	})
+	Handle(accounts, "deposited", func(state *account, data deposited) error {
+		if data.Amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+		state.Balance += data.Amount
+		return nil
+	})
+	return accounts
+}
+
+func TestRepository_Mock(t *testing.T) {
+	server := genesisdbtest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+	accounts := newAccounts(t, server)
+
+	agg := accounts.New("/account/1")
+	if err := accounts.Raise(agg, "account-opened", map[string]interface{}{"owner": "Bruce"}); err != nil {
+		t.Fatalf("Raise() error = %v", err)
+	}
+	if err := accounts.Raise(agg, "deposited", deposited{Amount: 100}); err != nil {
+		t.Fatalf("Raise() error = %v", err)
+	}
+	if err := accounts.Raise(agg, "deposited", deposited{Amount: -1}); err == nil {
+		t.Error("Raise() should return the handler error")
+	}
+	if err := accounts.Save(ctx, agg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if events := server.Events(); len(events) != 2 || agg.LastEventID != events[1].ID || len(agg.Pending()) != 0 {
+		t.Fatalf("Unexpected state after Save: %+v, stored %d events", agg, len(events))
+	}
+
+	t.Run("Load", func(t *testing.T) {
+		loaded, err := accounts.Load(ctx, "/account/1")
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if loaded.State != (account{Owner: "Bruce", Balance: 100}) || loaded.Version != 2 || loaded.LastEventID != agg.LastEventID {
+			t.Errorf("Unexpected aggregate: %+v", loaded)
+		}
+	})
+
+	t.Run("Save twice", func(t *testing.T) {
+		// The events of one commit share their time, so only their count
+		// tells whether the subject changed
+		agg := accounts.New("/account/3")
+		accounts.Raise(agg, "account-opened", map[string]interface{}{"owner": "Dick"})
+		accounts.Raise(agg, "deposited", deposited{Amount: 1})
+		accounts.Raise(agg, "deposited", deposited{Amount: 2})
+		if err := accounts.Save(ctx, agg); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		accounts.Raise(agg, "deposited", deposited{Amount: 3})
+		accounts.Raise(agg, "deposited", deposited{Amount: 4})
+		if err := accounts.Save(ctx, agg); err != nil {
+			t.Fatalf("second Save() error = %v", err)
+		}
+
+		accounts.Raise(agg, "deposited", deposited{Amount: 5})
+		if err := accounts.Save(ctx, agg); err != nil {
+			t.Fatalf("third Save() error = %v", err)
+		}
+
+		loaded, err := accounts.Load(ctx, "/account/3")
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if loaded.State.Balance != 15 || loaded.Version != 6 {
+			t.Errorf("Unexpected aggregate: %+v", loaded)
+		}
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		first, _ := accounts.Load(ctx, "/account/1")
+		second, _ := accounts.Load(ctx, "/account/1")
+
+		accounts.Raise(first, "deposited", deposited{Amount: 4})
+		accounts.Raise(first, "deposited", deposited{Amount: 6})
+		if err := accounts.Save(ctx, first); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		accounts.Raise(second, "deposited", deposited{Amount: 20})
+		accounts.Raise(second, "deposited", deposited{Amount: 30})
+		err := accounts.Save(ctx, second)
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) || !errors.Is(err, ErrConcurrencyConflict) || !errors.Is(err, genesisdb.ErrPreconditionFailed) {
+			t.Fatalf("Save() error = %v, want *ConflictError", err)
+		}
+		if conflict.Subject != "/account/1" || len(second.Pending()) != 2 {
+			t.Errorf("Unexpected conflict: %+v, pending %d", conflict, len(second.Pending()))
+		}
+
+		reloaded, _ := accounts.Load(ctx, "/account/1")
+		if reloaded.State.Balance != 110 {
+			t.Errorf("Balance = %d, want 110", reloaded.State.Balance)
+		}
+	})
+
+	t.Run("Conflict on new subject", func(t *testing.T) {
+		agg := accounts.New("/account/1")
+		accounts.Raise(agg, "account-opened", map[string]interface{}{"owner": "Alfred"})
+		if err := accounts.Save(ctx, agg); !errors.Is(err, ErrConcurrencyConflict) {
+			t.Errorf("Save() error = %v, want ErrConcurrencyConflict", err)
+		}
+	})
+
+	t.Run("Require handlers", func(t *testing.T) {
+		strict := newAccounts(t, server)
+		strict.RequireHandlers = true
+		agg := strict.New("/account/2")
+		if err := strict.Raise(agg, "withdrawn", nil); !errors.Is(err, ErrNoHandler) {
+			t.Errorf("Raise() error = %v, want ErrNoHandler", err)
+		}
+	})
+}
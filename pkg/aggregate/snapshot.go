@@ -0,0 +1,278 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+)
+
+// Snapshot is the state of an aggregate as of one of its events.
+type Snapshot struct {
+	Subject string `json:"subject"`
+	// EventID is the ID of the last event included in State.
+	EventID string          `json:"eventId"`
+	Version int             `json:"version"`
+	State   json.RawMessage `json:"state"`
+	Time    time.Time       `json:"time"`
+}
+
+// SnapshotStore keeps the latest snapshot of every subject.
+type SnapshotStore interface {
+	// Load returns the latest snapshot of subject, or nil if there is none.
+	Load(ctx context.Context, subject string) (*Snapshot, error)
+	Save(ctx context.Context, snapshot Snapshot) error
+}
+
+// SnapshotPolicy decides whether to store a snapshot of an aggregate, given
+// the number of events applied since its last snapshot and the time that
+// snapshot was taken, which is zero if there is none.
+type SnapshotPolicy func(eventsSinceSnapshot int, lastSnapshot time.Time) bool
+
+// EveryNEvents snapshots once n events were applied since the last snapshot.
+func EveryNEvents(n int) SnapshotPolicy {
+	return func(eventsSinceSnapshot int, _ time.Time) bool {
+		return eventsSinceSnapshot >= n
+	}
+}
+
+// EveryInterval snapshots aggregates with new events once interval has
+// passed since the last snapshot.
+func EveryInterval(interval time.Duration) SnapshotPolicy {
+	return func(eventsSinceSnapshot int, lastSnapshot time.Time) bool {
+		return eventsSinceSnapshot > 0 && time.Since(lastSnapshot) >= interval
+	}
+}
+
+// AnyPolicy snapshots when any of policies does.
+func AnyPolicy(policies ...SnapshotPolicy) SnapshotPolicy {
+	return func(eventsSinceSnapshot int, lastSnapshot time.Time) bool {
+		for _, policy := range policies {
+			if policy(eventsSinceSnapshot, lastSnapshot) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Snapshot stores a snapshot of the saved state of agg. Pending events are
+// not part of it and must be saved first.
+func (r *Repository[A]) Snapshot(ctx context.Context, agg *Aggregate[A]) error {
+	if r.Snapshots == nil {
+		return errors.New("repository has no snapshot store")
+	}
+	if len(agg.pending) > 0 {
+		return errors.New("aggregate has unsaved events")
+	}
+	if agg.LastEventID == "" {
+		return nil
+	}
+
+	state, err := json.Marshal(agg.State)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot of %s: %w", agg.Subject, err)
+	}
+
+	snapshot := Snapshot{
+		Subject: agg.Subject,
+		EventID: agg.LastEventID,
+		Version: agg.Version,
+		State:   state,
+		Time:    time.Now().UTC(),
+	}
+	if err := r.Snapshots.Save(ctx, snapshot); err != nil {
+		return err
+	}
+
+	agg.snapshotVersion = snapshot.Version
+	agg.snapshotTime = snapshot.Time
+	return nil
+}
+
+// maybeSnapshot stores a snapshot if the policy asks for one. Failures are
+// only reported to OnSnapshotError, since the aggregate itself is fine.
+func (r *Repository[A]) maybeSnapshot(ctx context.Context, agg *Aggregate[A]) {
+	if r.Snapshots == nil || r.SnapshotPolicy == nil {
+		return
+	}
+	if !r.SnapshotPolicy(agg.Version-agg.snapshotVersion, agg.snapshotTime) {
+		return
+	}
+	if err := r.Snapshot(ctx, agg); err != nil && r.OnSnapshotError != nil {
+		r.OnSnapshotError(err)
+	}
+}
+
+// restore loads the latest snapshot of subject into agg and returns the
+// stream options to read the events after it. Snapshots whose state no
+// longer decodes into A are ignored, so changing A only costs a full replay.
+func (r *Repository[A]) restore(ctx context.Context, agg *Aggregate[A]) (*genesisdb.StreamOptions, error) {
+	if r.Snapshots == nil {
+		return nil, nil
+	}
+
+	snapshot, err := r.Snapshots.Load(ctx, agg.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("error loading snapshot of %s: %w", agg.Subject, err)
+	}
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	state := r.New(agg.Subject).State
+	if err := json.Unmarshal(snapshot.State, &state); err != nil {
+		return nil, nil
+	}
+
+	agg.State = state
+	agg.Version = snapshot.Version
+	agg.LastEventID = snapshot.EventID
+	agg.snapshotVersion = snapshot.Version
+	agg.snapshotTime = snapshot.Time
+	return &genesisdb.StreamOptions{LowerBound: snapshot.EventID}, nil
+}
+
+// MemorySnapshotStore keeps snapshots in memory.
+type MemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]Snapshot
+}
+
+// NewMemorySnapshotStore creates an empty in-memory store.
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{snapshots: make(map[string]Snapshot)}
+}
+
+func (s *MemorySnapshotStore) Load(_ context.Context, subject string) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot, ok := s.snapshots[subject]
+	if !ok {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
+
+func (s *MemorySnapshotStore) Save(_ context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[snapshot.Subject] = snapshot
+	return nil
+}
+
+// FileSnapshotStore keeps every snapshot in a JSON file in a directory.
+type FileSnapshotStore struct {
+	dir string
+}
+
+// NewFileSnapshotStore creates a store that writes snapshots to dir, which
+// is created on the first Save.
+func NewFileSnapshotStore(dir string) *FileSnapshotStore {
+	return &FileSnapshotStore{dir: dir}
+}
+
+func (s *FileSnapshotStore) path(subject string) string {
+	return filepath.Join(s.dir, url.PathEscape(subject)+".json")
+}
+
+func (s *FileSnapshotStore) Load(_ context.Context, subject string) (*Snapshot, error) {
+	data, err := os.ReadFile(s.path(subject))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot file: %w", err)
+	}
+	return &snapshot, nil
+}
+
+func (s *FileSnapshotStore) Save(_ context.Context, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial
+	// snapshot
+	tmp, err := os.CreateTemp(s.dir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(snapshot.Subject))
+}
+
+// SnapshotEventType is the type of the events EventSnapshotStore stores
+// snapshots in.
+const SnapshotEventType = "io.genesisdb.snapshot-taken"
+
+// EventSnapshotStore keeps snapshots as events in GenesisDB itself, under a
+// separate subject for every aggregate subject.
+type EventSnapshotStore struct {
+	client *genesisdb.Genesisdb
+	source string
+	prefix string
+}
+
+// NewEventSnapshotStore creates a store that commits the snapshot of
+// subject as an event of SnapshotEventType to prefix+subject, e.g.
+// "/snapshots/account/1" for the prefix "/snapshots".
+func NewEventSnapshotStore(client *genesisdb.Genesisdb, source, prefix string) *EventSnapshotStore {
+	return &EventSnapshotStore{client: client, source: source, prefix: prefix}
+}
+
+func (s *EventSnapshotStore) Load(ctx context.Context, subject string) (*Snapshot, error) {
+	events, err := s.client.StreamEventsContext(ctx, s.prefix+subject, &genesisdb.StreamOptions{
+		LatestByEventType: SnapshotEventType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot *Snapshot
+	for _, event := range events {
+		if event.Subject != s.prefix+subject || event.Type != SnapshotEventType {
+			continue
+		}
+		decoded, err := genesisdb.DecodeData[Snapshot](event)
+		if err != nil {
+			return nil, err
+		}
+		snapshot = &decoded
+	}
+	return snapshot, nil
+}
+
+func (s *EventSnapshotStore) Save(ctx context.Context, snapshot Snapshot) error {
+	return s.client.CommitEventsContext(ctx, []genesisdb.Event{{
+		Source:  s.source,
+		Subject: s.prefix + snapshot.Subject,
+		Type:    SnapshotEventType,
+		Data:    snapshot,
+	}})
+}
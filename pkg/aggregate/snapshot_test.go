@@ -0,0 +1,171 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdbtest"
+)
+
+func TestSnapshotPolicy(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		policy       SnapshotPolicy
+		events       int
+		lastSnapshot time.Time
+		want         bool
+	}{
+		{"Below N", EveryNEvents(3), 2, now, false},
+		{"At N", EveryNEvents(3), 3, now, true},
+		{"Interval not passed", EveryInterval(time.Hour), 1, now, false},
+		{"Interval passed", EveryInterval(time.Hour), 1, now.Add(-2 * time.Hour), true},
+		{"No snapshot yet", EveryInterval(time.Hour), 1, time.Time{}, true},
+		{"Interval without events", EveryInterval(time.Hour), 0, time.Time{}, false},
+		{"Any", AnyPolicy(EveryNEvents(100), EveryInterval(time.Hour)), 1, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy(tt.events, tt.lastSnapshot); got != tt.want {
+				t.Errorf("policy(%d, %v) = %v, want %v", tt.events, tt.lastSnapshot, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotStores(t *testing.T) {
+	server := genesisdbtest.NewServer()
+	defer server.Close()
+	client, _ := server.NewClient()
+
+	stores := map[string]SnapshotStore{
+		"Memory": NewMemorySnapshotStore(),
+		"File":   NewFileSnapshotStore(t.TempDir() + "/snapshots"),
+		"Event":  NewEventSnapshotStore(client, "io.genesisdb.bank", "/snapshots"),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			snapshot, err := store.Load(ctx, "/account/1")
+			if err != nil || snapshot != nil {
+				t.Fatalf("Load() = %v, %v, want no snapshot", snapshot, err)
+			}
+
+			for version, id := range []string{"e1", "e2"} {
+				err := store.Save(ctx, Snapshot{
+					Subject: "/account/1",
+					EventID: id,
+					Version: version + 1,
+					State:   json.RawMessage(`{"Balance":1}`),
+					Time:    time.Now().UTC(),
+				})
+				if err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+			}
+
+			snapshot, err = store.Load(ctx, "/account/1")
+			if err != nil || snapshot == nil {
+				t.Fatalf("Load() = %v, %v", snapshot, err)
+			}
+			if snapshot.EventID != "e2" || snapshot.Version != 2 || string(snapshot.State) != `{"Balance":1}` {
+				t.Errorf("Unexpected snapshot: %+v", snapshot)
+			}
+		})
+	}
+}
+
+func TestRepositorySnapshots_Mock(t *testing.T) {
+	server := genesisdbtest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	store := NewMemorySnapshotStore()
+	accounts := newAccounts(t, server)
+	accounts.Snapshots = store
+	accounts.SnapshotPolicy = EveryNEvents(3)
+	accounts.OnSnapshotError = func(err error) {
+		t.Errorf("Unexpected snapshot error: %v", err)
+	}
+
+	agg := accounts.New("/account/1")
+	accounts.Raise(agg, "account-opened", map[string]interface{}{"owner": "Bruce"})
+	accounts.Raise(agg, "deposited", deposited{Amount: 100})
+	if err := accounts.Save(ctx, agg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if snapshot, _ := store.Load(ctx, "/account/1"); snapshot != nil {
+		t.Fatalf("Unexpected snapshot after 2 events: %+v", snapshot)
+	}
+
+	accounts.Raise(agg, "deposited", deposited{Amount: 50})
+	if err := accounts.Save(ctx, agg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	snapshot, _ := store.Load(ctx, "/account/1")
+	if snapshot == nil || snapshot.EventID != server.Events()[2].ID || snapshot.Version != 3 {
+		t.Fatalf("Unexpected snapshot after 3 events: %+v", snapshot)
+	}
+
+	accounts.Raise(agg, "deposited", deposited{Amount: 25})
+	if err := accounts.Save(ctx, agg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	t.Run("Resume from snapshot", func(t *testing.T) {
+		// The history before the snapshot must not be read again
+		resumed := newAccounts(t, server)
+		resumed.Snapshots = store
+		resumed.On("account-opened", func(state *account, event genesisdb.Event) error {
+			t.Error("Event before the snapshot applied again")
+			return nil
+		})
+
+		loaded, err := resumed.Load(ctx, "/account/1")
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if loaded.State != (account{Owner: "Bruce", Balance: 175}) || loaded.Version != 4 || loaded.LastEventID != server.Events()[3].ID {
+			t.Errorf("Unexpected aggregate: %+v", loaded)
+		}
+
+		// The restored version guards the next save
+		resumed.Raise(loaded, "deposited", deposited{Amount: 5})
+		if err := resumed.Save(ctx, loaded); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	})
+
+	t.Run("Undecodable snapshot", func(t *testing.T) {
+		snapshot.State = json.RawMessage(`{"Balance":"many"}`)
+		store.Save(ctx, *snapshot)
+
+		loaded, err := accounts.Load(ctx, "/account/1")
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if loaded.State.Balance != 180 || loaded.Version != 5 {
+			t.Errorf("Unexpected aggregate: %+v", loaded)
+		}
+	})
+
+	t.Run("Unsaved events", func(t *testing.T) {
+		accounts.Raise(agg, "deposited", deposited{Amount: 1})
+		if err := accounts.Snapshot(ctx, agg); err == nil {
+			t.Error("Snapshot() should fail with pending events")
+		}
+	})
+
+	t.Run("No store", func(t *testing.T) {
+		if err := newAccounts(t, server).Snapshot(ctx, agg); err == nil {
+			t.Error("Snapshot() should fail without a store")
+		}
+	})
+}
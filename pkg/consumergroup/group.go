@@ -0,0 +1,330 @@
+// Package consumergroup spreads the work of handling the events of a subject
+// across several processes.
+//
+// The members of a group split the events by their subject: every subject
+// belongs to one of a fixed number of partitions, and the partitions are
+// assigned to the members by consistent hashing. Each member handles the
+// events of its partitions with a projection per partition, so events of
+// the same subject are handled by one member at a time and in order.
+//
+// Every projection observes the whole subject and passes over the events of
+// other partitions, so a member opens one observation per partition it
+// handles and reads every event that many times. Choose the number of
+// partitions close to the number of members you expect to run, and raise it
+// only to spread the work more evenly.
+//
+// Members find each other through a LeaseBackend: every member holds a
+// lease that it renews while it runs, and a lease per partition it handles.
+// When a member joins or leaves, the partitions are reassigned. The member
+// giving up a partition stops its projection before releasing the lease,
+// and the member taking it over resumes from the partition's checkpoint,
+// so the CheckpointStore must be shared by all members.
+package consumergroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/projection"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPartitions = 4
+	defaultLeaseTTL   = 15 * time.Second
+)
+
+// ErrMemberConflict is reported to OnError while another process holds the
+// lease of the same Member name. The member waits until the lease is
+// released or expires, e.g. after the previous process of a restarted
+// member crashed.
+var ErrMemberConflict = errors.New("member name is in use")
+
+type registeredHandler struct {
+	handler projection.Handler
+	policy  projection.ErrorPolicy
+}
+
+// Group is a member of a consumer group.
+type Group struct {
+	// Member names this member within the group. Defaults to a random
+	// UUID, set it to keep the partitions across restarts.
+	Member string
+	// Partitions is the number of partitions the subjects are split into.
+	// All members of the group must use the same number. Each partition
+	// costs its member an observation reading all events of the subject.
+	// Defaults to 4.
+	Partitions int
+	// LeaseTTL is how long the leases of a member last. They are renewed
+	// at a third of it, and a member that crashed is replaced after it.
+	// Defaults to 15 seconds.
+	LeaseTTL time.Duration
+	// Reconnect controls how the observations reconnect after the
+	// connection dropped. Nil uses the defaults of ReconnectPolicy.
+	Reconnect *genesisdb.ReconnectPolicy
+	// OnError, if set, is called with every handler failure and with
+	// errors of the observations and the lease backend, in which case
+	// event is empty.
+	OnError func(event genesisdb.Event, err error)
+	// OnRebalance, if set, is called with the partitions this member
+	// handles whenever they change.
+	OnRebalance func(partitions []int)
+
+	client      *genesisdb.Genesisdb
+	name        string
+	subject     string
+	leases      LeaseBackend
+	checkpoints projection.CheckpointStore
+	handlers    map[string]registeredHandler
+
+	// holder identifies this process in the leases, telling apart two
+	// processes that use the same Member name
+	holder string
+
+	mu      sync.Mutex
+	workers map[int]*worker
+	owned   []int
+}
+
+// worker runs the projection of a partition.
+type worker struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (w *worker) stop() {
+	w.cancel()
+	<-w.done
+}
+
+func (w *worker) stopped() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// New creates a member of the group name handling the events of subject.
+// The members coordinate through leases and keep the checkpoints of the
+// partitions in checkpoints, both of which all members must share.
+func New(client *genesisdb.Genesisdb, name, subject string, leases LeaseBackend, checkpoints projection.CheckpointStore) *Group {
+	return &Group{
+		client:      client,
+		name:        name,
+		subject:     subject,
+		leases:      leases,
+		checkpoints: checkpoints,
+		handlers:    make(map[string]registeredHandler),
+		holder:      uuid.New().String(),
+		workers:     make(map[int]*worker),
+	}
+}
+
+// On registers the handler for events of eventType and what to do when it
+// fails. Events without a handler are passed over.
+func (g *Group) On(eventType string, handler projection.Handler, policy projection.ErrorPolicy) *Group {
+	g.handlers[eventType] = registeredHandler{handler: handler, policy: policy}
+	return g
+}
+
+// Owned returns the partitions this member currently handles.
+func (g *Group) Owned() []int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]int(nil), g.owned...)
+}
+
+// Run joins the group and handles the events of the partitions assigned to
+// this member until ctx is done. A partition whose projection stops with an
+// error is restarted from its checkpoint at the next renewal of the leases.
+// On return all partitions are stopped and the leases released, so the
+// other members take over right away. Run returns ctx.Err().
+func (g *Group) Run(ctx context.Context) error {
+	if g.Member == "" {
+		g.Member = g.holder
+	}
+	if g.Partitions <= 0 {
+		g.Partitions = defaultPartitions
+	}
+	if g.LeaseTTL <= 0 {
+		g.LeaseTTL = defaultLeaseTTL
+	}
+	defer g.leave()
+
+	ticker := time.NewTicker(g.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := g.rebalance(ctx); err != nil && ctx.Err() == nil {
+			g.report(genesisdb.Event{}, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// rebalance renews the leases of the member, stops the partitions now
+// assigned to other members and starts the ones assigned to it.
+func (g *Group) rebalance(ctx context.Context) error {
+	ok, err := g.leases.Acquire(ctx, g.memberKey(g.Member), g.holder, g.LeaseTTL)
+	if err != nil {
+		return fmt.Errorf("error renewing membership: %w", err)
+	}
+	if !ok {
+		return ErrMemberConflict
+	}
+
+	prefix := g.memberKey("")
+	holders, err := g.leases.List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("error listing members: %w", err)
+	}
+	members := make([]string, 0, len(holders))
+	for key := range holders {
+		members = append(members, strings.TrimPrefix(key, prefix))
+	}
+	ring := NewRing(members)
+
+	g.mu.Lock()
+	var errs []error
+	for partition, w := range g.workers {
+		keep := !w.stopped() && ring.Owner(strconv.Itoa(partition)) == g.Member
+		if keep {
+			// A lease lost to another member, e.g. after a long pause,
+			// stops the partition as well
+			keep, err = g.leases.Acquire(ctx, g.partitionKey(partition), g.holder, g.LeaseTTL)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error renewing lease of partition %d: %w", partition, err))
+				continue
+			}
+		}
+		if keep {
+			continue
+		}
+
+		// Hand the partition off only once its checkpoint is final
+		w.stop()
+		delete(g.workers, partition)
+		if err := g.leases.Release(ctx, g.partitionKey(partition), g.holder); err != nil {
+			errs = append(errs, fmt.Errorf("error releasing partition %d: %w", partition, err))
+		}
+	}
+
+	for partition := 0; partition < g.Partitions; partition++ {
+		if _, running := g.workers[partition]; running || ring.Owner(strconv.Itoa(partition)) != g.Member {
+			continue
+		}
+		// The previous owner may still be stopping, the partition is then
+		// picked up at the next renewal
+		ok, err := g.leases.Acquire(ctx, g.partitionKey(partition), g.holder, g.LeaseTTL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error acquiring partition %d: %w", partition, err))
+			continue
+		}
+		if ok {
+			g.workers[partition] = g.start(ctx, partition)
+		}
+	}
+
+	owned, changed := g.updateOwned()
+	g.mu.Unlock()
+
+	g.notify(owned, changed)
+	return errors.Join(errs...)
+}
+
+// start runs the projection of partition until ctx is done or the worker
+// is stopped.
+func (g *Group) start(ctx context.Context, partition int) *worker {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &worker{cancel: cancel, done: make(chan struct{})}
+
+	p := projection.New(g.client, g.name+"/"+strconv.Itoa(partition), g.subject, g.checkpoints)
+	p.Reconnect = g.Reconnect
+	p.OnError = g.OnError
+	for eventType, registered := range g.handlers {
+		handler := registered.handler
+		p.On(eventType, func(ctx context.Context, event genesisdb.Event) error {
+			if Partition(event.Subject, g.Partitions) != partition {
+				return nil
+			}
+			return handler(ctx, event)
+		}, registered.policy)
+	}
+
+	go func() {
+		defer close(w.done)
+		err := p.Run(ctx)
+		if ctx.Err() == nil {
+			g.report(genesisdb.Event{}, fmt.Errorf("partition %d stopped: %w", partition, err))
+		}
+	}()
+	return w
+}
+
+// updateOwned records the partitions of the running workers and reports
+// whether they changed.
+func (g *Group) updateOwned() ([]int, bool) {
+	owned := make([]int, 0, len(g.workers))
+	for partition := range g.workers {
+		owned = append(owned, partition)
+	}
+	sort.Ints(owned)
+
+	changed := len(owned) != len(g.owned)
+	for i := 0; !changed && i < len(owned); i++ {
+		changed = owned[i] != g.owned[i]
+	}
+	g.owned = owned
+	return append([]int(nil), owned...), changed
+}
+
+func (g *Group) notify(owned []int, changed bool) {
+	if changed && g.OnRebalance != nil {
+		g.OnRebalance(owned)
+	}
+}
+
+// leave stops all partitions and releases the leases of the member.
+func (g *Group) leave() {
+	ctx := context.Background()
+
+	g.mu.Lock()
+	for partition, w := range g.workers {
+		w.stop()
+		delete(g.workers, partition)
+		g.leases.Release(ctx, g.partitionKey(partition), g.holder)
+	}
+	g.leases.Release(ctx, g.memberKey(g.Member), g.holder)
+	owned, changed := g.updateOwned()
+	g.mu.Unlock()
+
+	g.notify(owned, changed)
+}
+
+func (g *Group) report(event genesisdb.Event, err error) {
+	if g.OnError != nil {
+		g.OnError(event, err)
+	}
+}
+
+func (g *Group) memberKey(member string) string {
+	return g.name + "/members/" + member
+}
+
+func (g *Group) partitionKey(partition int) string {
+	return g.name + "/partitions/" + strconv.Itoa(partition)
+}
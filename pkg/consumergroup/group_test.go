@@ -0,0 +1,182 @@
+package consumergroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdbtest"
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/projection"
+)
+
+// orderEvents returns the events from..to, spread over ten subjects.
+func orderEvents(from, to int) []genesisdb.Event {
+	var events []genesisdb.Event
+	for i := from; i <= to; i++ {
+		events = append(events, genesisdb.Event{
+			ID:      "e" + strconv.Itoa(i),
+			Subject: fmt.Sprintf("/orders/%d", i%10),
+			Type:    "order-placed",
+		})
+	}
+	return events
+}
+
+func newClient(t *testing.T, server *genesisdbtest.Server) *genesisdb.Genesisdb {
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+// recorder records which members handled which events.
+type recorder struct {
+	mu      sync.Mutex
+	handled map[string][]string
+}
+
+func (r *recorder) handler(member string) projection.Handler {
+	return func(_ context.Context, event genesisdb.Event) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.handled[event.ID] = append(r.handled[event.ID], member)
+		return nil
+	}
+}
+
+func (r *recorder) members(id string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.handled[id]
+}
+
+// handledAll reports whether the events from..to have been handled.
+func handledAll(rec *recorder, from, to int) func() bool {
+	return func() bool {
+		for i := from; i <= to; i++ {
+			if len(rec.members("e"+strconv.Itoa(i))) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGroup_Mock(t *testing.T) {
+	server := genesisdbtest.NewServer(orderEvents(1, 20)...)
+	defer server.Close()
+
+	leases := NewMemoryLeaseBackend()
+	checkpoints := projection.NewMemoryCheckpointStore()
+	rec := &recorder{handled: make(map[string][]string)}
+
+	join := func(member string) (*Group, context.CancelFunc, chan error) {
+		g := New(newClient(t, server), "billing", "/orders", leases, checkpoints).On("order-placed", rec.handler(member), projection.StopOnError)
+		g.Member = member
+		g.LeaseTTL = 150 * time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- g.Run(ctx) }()
+		return g, cancel, done
+	}
+	checkOwners := func(from, to int, owner func(partition int) string) {
+		t.Helper()
+		for i := from; i <= to; i++ {
+			id := "e" + strconv.Itoa(i)
+			want := owner(Partition(fmt.Sprintf("/orders/%d", i%10), defaultPartitions))
+			if got := rec.members(id); len(got) != 1 || got[0] != want {
+				t.Errorf("Event %s handled by %v, want [%s]", id, got, want)
+			}
+		}
+	}
+
+	a, cancelA, _ := join("a")
+	defer cancelA()
+	eventually(t, "a to handle the history", handledAll(rec, 1, 20))
+	checkOwners(1, 20, func(int) string { return "a" })
+
+	// b joins and takes over part of the partitions at their checkpoints
+	b, cancelB, doneB := join("b")
+	ring := NewRing([]string{"a", "b"})
+	eventually(t, "rebalance", func() bool {
+		return len(a.Owned())+len(b.Owned()) == defaultPartitions && len(b.Owned()) > 0 && len(a.Owned()) > 0
+	})
+	for _, partition := range b.Owned() {
+		if owner := ring.Owner(strconv.Itoa(partition)); owner != "b" {
+			t.Errorf("b owns partition %d of %s", partition, owner)
+		}
+	}
+
+	server.Append(orderEvents(21, 40)...)
+	eventually(t, "both to handle new events", handledAll(rec, 21, 40))
+	checkOwners(1, 20, func(int) string { return "a" })
+	checkOwners(21, 40, func(partition int) string { return ring.Owner(strconv.Itoa(partition)) })
+
+	// b leaves and hands its partitions back
+	cancelB()
+	if err := <-doneB; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	eventually(t, "a to take over", func() bool { return len(a.Owned()) == defaultPartitions })
+
+	server.Append(orderEvents(41, 50)...)
+	eventually(t, "a to handle new events", handledAll(rec, 41, 50))
+	checkOwners(41, 50, func(int) string { return "a" })
+}
+
+func TestGroup_MemberConflict(t *testing.T) {
+	server := genesisdbtest.NewServer(orderEvents(1, 1)...)
+	defer server.Close()
+
+	// Another process holds the lease of member a
+	leases := NewMemoryLeaseBackend()
+	leases.Acquire(context.Background(), "billing/members/a", "other", time.Minute)
+
+	rec := &recorder{handled: make(map[string][]string)}
+	conflicts := make(chan error, 10)
+	g := New(newClient(t, server), "billing", "/orders", leases, projection.NewMemoryCheckpointStore()).On("order-placed", rec.handler("a"), projection.StopOnError)
+	g.Member = "a"
+	g.LeaseTTL = 60 * time.Millisecond
+	g.OnError = func(_ genesisdb.Event, err error) {
+		select {
+		case conflicts <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go g.Run(ctx)
+
+	select {
+	case err := <-conflicts:
+		if !errors.Is(err, ErrMemberConflict) {
+			t.Fatalf("OnError() error = %v, want ErrMemberConflict", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for conflict")
+	}
+	if len(g.Owned()) != 0 || len(rec.members("e1")) != 0 {
+		t.Fatal("Member ran while its name was in use")
+	}
+
+	leases.Release(context.Background(), "billing/members/a", "other")
+	eventually(t, "a to take over", handledAll(rec, 1, 1))
+}
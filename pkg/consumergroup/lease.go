@@ -0,0 +1,207 @@
+package consumergroup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LeaseBackend coordinates the members of consumer groups. A lease on a key
+// belongs to one holder until it expires or is released.
+type LeaseBackend interface {
+	// Acquire takes the lease on key for holder, or renews it if holder
+	// already has it, until ttl has passed. It reports false if another
+	// holder has a lease on key that has not expired.
+	Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease on key if holder has it.
+	Release(ctx context.Context, key, holder string) error
+	// List returns the holders of the unexpired leases whose keys start
+	// with prefix, by key.
+	List(ctx context.Context, prefix string) (map[string]string, error)
+}
+
+type lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// leaseTable implements the lease rules shared by the backends.
+type leaseTable map[string]lease
+
+func (t leaseTable) acquire(key, holder string, ttl time.Duration, now time.Time) bool {
+	if current, ok := t[key]; ok && current.Holder != holder && now.Before(current.Expires) {
+		return false
+	}
+	t[key] = lease{Holder: holder, Expires: now.Add(ttl)}
+	return true
+}
+
+func (t leaseTable) release(key, holder string) {
+	if current, ok := t[key]; ok && current.Holder == holder {
+		delete(t, key)
+	}
+}
+
+func (t leaseTable) list(prefix string, now time.Time) map[string]string {
+	holders := make(map[string]string)
+	for key, current := range t {
+		if strings.HasPrefix(key, prefix) && now.Before(current.Expires) {
+			holders[key] = current.Holder
+		}
+	}
+	return holders
+}
+
+func (t leaseTable) expire(now time.Time) {
+	for key, current := range t {
+		if !now.Before(current.Expires) {
+			delete(t, key)
+		}
+	}
+}
+
+// MemoryLeaseBackend keeps leases in memory, for members of a group that
+// run in the same process.
+type MemoryLeaseBackend struct {
+	mu     sync.Mutex
+	leases leaseTable
+}
+
+// NewMemoryLeaseBackend creates a backend without leases.
+func NewMemoryLeaseBackend() *MemoryLeaseBackend {
+	return &MemoryLeaseBackend{leases: make(leaseTable)}
+}
+
+func (b *MemoryLeaseBackend) Acquire(_ context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.leases.acquire(key, holder, ttl, time.Now()), nil
+}
+
+func (b *MemoryLeaseBackend) Release(_ context.Context, key, holder string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.leases.release(key, holder)
+	return nil
+}
+
+func (b *MemoryLeaseBackend) List(_ context.Context, prefix string) (map[string]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.leases.list(prefix, time.Now()), nil
+}
+
+// staleLockAge is the age after which a lock file is considered left behind
+// by a crashed process and removed.
+const staleLockAge = 10 * time.Second
+
+// FileLeaseBackend keeps leases in a file, for members of a group that run
+// on the same host or share a file system. Access to the file is serialized
+// with a lock file.
+type FileLeaseBackend struct {
+	dir string
+}
+
+// NewFileLeaseBackend creates a backend that keeps its files in dir, which
+// is created if needed.
+func NewFileLeaseBackend(dir string) (*FileLeaseBackend, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileLeaseBackend{dir: dir}, nil
+}
+
+func (b *FileLeaseBackend) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	var acquired bool
+	err := b.update(ctx, func(leases leaseTable, now time.Time) bool {
+		acquired = leases.acquire(key, holder, ttl, now)
+		return acquired
+	})
+	return acquired, err
+}
+
+func (b *FileLeaseBackend) Release(ctx context.Context, key, holder string) error {
+	return b.update(ctx, func(leases leaseTable, _ time.Time) bool {
+		leases.release(key, holder)
+		return true
+	})
+}
+
+func (b *FileLeaseBackend) List(ctx context.Context, prefix string) (map[string]string, error) {
+	var holders map[string]string
+	err := b.update(ctx, func(leases leaseTable, now time.Time) bool {
+		holders = leases.list(prefix, now)
+		return false
+	})
+	return holders, err
+}
+
+// update runs fn on the leases while holding the lock and writes them back
+// if fn reports a change.
+func (b *FileLeaseBackend) update(ctx context.Context, fn func(leases leaseTable, now time.Time) bool) error {
+	unlock, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path := filepath.Join(b.dir, "leases.json")
+	leases := make(leaseTable)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &leases); err != nil {
+			return fmt.Errorf("error decoding leases: %w", err)
+		}
+	}
+
+	now := time.Now()
+	if !fn(leases, now) {
+		return nil
+	}
+	leases.expire(now)
+
+	if data, err = json.Marshal(leases); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lock creates the lock file, waiting while another process holds it.
+func (b *FileLeaseBackend) lock(ctx context.Context) (func(), error) {
+	path := filepath.Join(b.dir, "leases.lock")
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
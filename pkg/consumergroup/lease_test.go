@@ -0,0 +1,98 @@
+package consumergroup
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLeaseBackends(t *testing.T) {
+	files, err := NewFileLeaseBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileLeaseBackend() error = %v", err)
+	}
+	backends := map[string]LeaseBackend{
+		"Memory": NewMemoryLeaseBackend(),
+		"File":   files,
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			acquire := func(key, holder string, ttl time.Duration) bool {
+				t.Helper()
+				ok, err := backend.Acquire(ctx, key, holder, ttl)
+				if err != nil {
+					t.Fatalf("Acquire() error = %v", err)
+				}
+				return ok
+			}
+
+			if !acquire("g/members/a", "a", time.Minute) {
+				t.Fatal("Acquire() of free lease failed")
+			}
+			if acquire("g/members/a", "b", time.Minute) {
+				t.Error("Acquire() of held lease succeeded")
+			}
+			if !acquire("g/members/a", "a", time.Minute) {
+				t.Error("Renewal failed")
+			}
+			acquire("g/members/b", "b", 20*time.Millisecond)
+			acquire("g/partitions/0", "a", time.Minute)
+
+			holders, _ := backend.List(ctx, "g/members/")
+			if len(holders) != 2 || holders["g/members/a"] != "a" || holders["g/members/b"] != "b" {
+				t.Errorf("List() = %v", holders)
+			}
+
+			time.Sleep(30 * time.Millisecond)
+			if holders, _ := backend.List(ctx, "g/members/"); len(holders) != 1 {
+				t.Errorf("List() after expiry = %v", holders)
+			}
+			if !acquire("g/members/b", "c", time.Minute) {
+				t.Error("Acquire() of expired lease failed")
+			}
+
+			backend.Release(ctx, "g/partitions/0", "b")
+			if acquire("g/partitions/0", "b", time.Minute) {
+				t.Error("Release() by other holder freed the lease")
+			}
+			backend.Release(ctx, "g/partitions/0", "a")
+			if !acquire("g/partitions/0", "b", time.Minute) {
+				t.Error("Acquire() of released lease failed")
+			}
+		})
+	}
+}
+
+func TestFileLeaseBackend_Shared(t *testing.T) {
+	dir := t.TempDir()
+	first, _ := NewFileLeaseBackend(dir)
+	second, _ := NewFileLeaseBackend(dir)
+	ctx := context.Background()
+
+	done := make(chan bool)
+	for i := 0; i < 20; i++ {
+		go func(i int) {
+			backend := first
+			if i%2 == 1 {
+				backend = second
+			}
+			ok, err := backend.Acquire(ctx, "g/partitions/0", string(rune('a'+i)), time.Minute)
+			if err != nil {
+				t.Errorf("Acquire() error = %v", err)
+			}
+			done <- ok
+		}(i)
+	}
+
+	acquired := 0
+	for i := 0; i < 20; i++ {
+		if <-done {
+			acquired++
+		}
+	}
+	if acquired != 1 {
+		t.Errorf("%d holders acquired the lease, want 1", acquired)
+	}
+}
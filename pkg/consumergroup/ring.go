@@ -0,0 +1,65 @@
+package consumergroup
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// virtualNodes is the number of points every member has on the ring, which
+// spreads the partitions evenly across members.
+const virtualNodes = 128
+
+// Ring assigns keys to members by consistent hashing, so a member joining
+// or leaving only moves the keys it takes over or leaves behind.
+type Ring struct {
+	points  []uint64
+	members map[uint64]string
+}
+
+// NewRing creates a ring of the given members.
+func NewRing(members []string) *Ring {
+	r := &Ring{members: make(map[uint64]string, len(members)*virtualNodes)}
+	for _, member := range members {
+		for i := 0; i < virtualNodes; i++ {
+			point := hash(member + "#" + strconv.Itoa(i))
+			r.points = append(r.points, point)
+			r.members[point] = member
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Owner returns the member key belongs to, or an empty string if the ring
+// has no members.
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.members[r.points[i]]
+}
+
+// Partition returns the partition of subject among the given number of
+// partitions.
+func Partition(subject string, partitions int) int {
+	return int(hash(subject) % uint64(partitions))
+}
+
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// Mix the bits, FNV alone clusters similar keys on the ring
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,57 @@
+package consumergroup
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		if owner := NewRing(nil).Owner("1"); owner != "" {
+			t.Errorf("Owner() = %q, want empty", owner)
+		}
+	})
+
+	t.Run("Spreads keys", func(t *testing.T) {
+		ring := NewRing([]string{"a", "b", "c"})
+		counts := make(map[string]int)
+		for i := 0; i < 3000; i++ {
+			counts[ring.Owner(strconv.Itoa(i))]++
+		}
+		for _, member := range []string{"a", "b", "c"} {
+			if counts[member] < 600 {
+				t.Errorf("Member %s owns %d of 3000 keys", member, counts[member])
+			}
+		}
+	})
+
+	t.Run("Moves only keys of joining member", func(t *testing.T) {
+		before := NewRing([]string{"a", "b"})
+		after := NewRing([]string{"b", "a", "c"})
+		for i := 0; i < 1000; i++ {
+			key := strconv.Itoa(i)
+			if owner := after.Owner(key); owner != "c" && owner != before.Owner(key) {
+				t.Fatalf("Key %s moved from %s to %s", key, before.Owner(key), owner)
+			}
+		}
+	})
+}
+
+func TestPartition(t *testing.T) {
+	seen := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		subject := fmt.Sprintf("/orders/%d", i)
+		partition := Partition(subject, 8)
+		if partition < 0 || partition >= 8 {
+			t.Fatalf("Partition(%s) = %d", subject, partition)
+		}
+		if Partition(subject, 8) != partition {
+			t.Fatalf("Partition(%s) is not stable", subject)
+		}
+		seen[partition] = true
+	}
+	if len(seen) != 8 {
+		t.Errorf("Subjects fall into %d of 8 partitions", len(seen))
+	}
+}
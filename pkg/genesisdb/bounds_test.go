@@ -0,0 +1,171 @@
+package genesisdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *StreamOptions
+		wantErr bool
+	}{
+		{"Nil options", nil, false},
+		{"Lower bound only", &StreamOptions{LowerBound: "a", IncludeLowerBoundEvent: true}, false},
+		{"Both bounds", &StreamOptions{LowerBound: "a", UpperBound: "b", IncludeUpperBoundEvent: true}, false},
+		{"Equal bounds included", &StreamOptions{LowerBound: "a", UpperBound: "a", IncludeLowerBoundEvent: true, IncludeUpperBoundEvent: true}, false},
+		{"Include lower without bound", &StreamOptions{IncludeLowerBoundEvent: true}, true},
+		{"Include upper without bound", &StreamOptions{IncludeUpperBoundEvent: true}, true},
+		{"Equal bounds excluded", &StreamOptions{LowerBound: "a", UpperBound: "a", IncludeLowerBoundEvent: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidStreamOptions) {
+				t.Errorf("Validate() error = %v, want ErrInvalidStreamOptions", err)
+			}
+		})
+	}
+}
+
+func TestUpperBound_Mock(t *testing.T) {
+	config := &Config{
+		APIVersion: "v1",
+		AuthToken:  "test-token",
+	}
+
+	newServer := func(t *testing.T, want map[string]interface{}) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Options map[string]interface{} `json:"options"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for key, value := range want {
+				if body.Options[key] != value {
+					t.Errorf("options[%s] = %v, want %v", key, body.Options[key], value)
+				}
+			}
+
+			eventJSON, _ := json.Marshal(Event{ID: "b", Subject: "/test", Type: "test.event"})
+			w.WriteHeader(200)
+			w.Write([]byte(string(eventJSON) + "\n"))
+		}))
+	}
+
+	t.Run("Stream sends both bounds", func(t *testing.T) {
+		server := newServer(t, map[string]interface{}{
+			"lowerBound":             "a",
+			"includeLowerBoundEvent": true,
+			"upperBound":             "b",
+			"includeUpperBoundEvent": true,
+		})
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		options := &StreamOptions{LowerBound: "a", IncludeLowerBoundEvent: true, UpperBound: "b", IncludeUpperBoundEvent: true}
+		if _, err := client.StreamEvents("/test", options); err != nil {
+			t.Fatalf("StreamEvents() error = %v", err)
+		}
+	})
+
+	t.Run("Observe sends upper bound", func(t *testing.T) {
+		server := newServer(t, map[string]interface{}{"upperBound": "b"})
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		sub := client.Observe(context.Background(), "/test", &StreamOptions{UpperBound: "b"})
+		for range sub.Events() {
+		}
+		if !errors.Is(sub.Err(), ErrStreamEnded) {
+			t.Errorf("Err() = %v, want ErrStreamEnded", sub.Err())
+		}
+	})
+
+	t.Run("Invalid bounds are rejected before sending", func(t *testing.T) {
+		client, _ := NewClient(&Config{APIURL: "http://localhost:1", APIVersion: "v1", AuthToken: "test-token"}, WithTransport(&mockRoundTripper{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				t.Error("Request should not be sent")
+				return nil, errors.New("unexpected request")
+			},
+		}))
+
+		options := &StreamOptions{IncludeUpperBoundEvent: true}
+		if _, err := client.StreamEvents("/test", options); !errors.Is(err, ErrInvalidStreamOptions) {
+			t.Errorf("StreamEvents() error = %v, want ErrInvalidStreamOptions", err)
+		}
+
+		sub := client.ObserveWithReconnect(context.Background(), "/test", options, nil)
+		<-sub.Done()
+		if !errors.Is(sub.Err(), ErrInvalidStreamOptions) {
+			t.Errorf("ObserveWithReconnect() Err() = %v, want ErrInvalidStreamOptions", sub.Err())
+		}
+	})
+
+	t.Run("Reconnect stops at upper bound", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			eventJSON, _ := json.Marshal(Event{ID: "b", Subject: "/test", Type: "test.event"})
+			w.WriteHeader(200)
+			w.Write([]byte(string(eventJSON) + "\n"))
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		options := &StreamOptions{UpperBound: "b", IncludeUpperBoundEvent: true}
+		sub := client.ObserveWithReconnect(context.Background(), "/test", options, &ReconnectPolicy{InitialBackoff: time.Millisecond})
+		for range sub.Events() {
+		}
+		if !errors.Is(sub.Err(), ErrStreamEnded) || requests != 1 {
+			t.Errorf("Err() = %v after %d requests, want ErrStreamEnded after 1", sub.Err(), requests)
+		}
+	})
+	t.Run("Reconnect stops at exclusive upper bound", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(200)
+			for _, id := range []string{"a", "b"} {
+				eventJSON, _ := json.Marshal(Event{ID: id, Subject: "/test", Type: "test.event"})
+				w.Write([]byte(string(eventJSON) + "\n"))
+			}
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		options := &StreamOptions{UpperBound: "c"}
+		sub := client.ObserveWithReconnect(context.Background(), "/test", options, &ReconnectPolicy{InitialBackoff: time.Millisecond})
+		var ids []string
+		for event := range sub.Events() {
+			ids = append(ids, event.ID)
+		}
+		if !errors.Is(sub.Err(), ErrStreamEnded) || requests != 1 {
+			t.Errorf("Err() = %v after %d requests, want ErrStreamEnded after 1", sub.Err(), requests)
+		}
+		if len(ids) != 2 {
+			t.Errorf("delivered %v, want [a b]", ids)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package genesisdb
+
+import "context"
+
+// dedupWindow is the number of recently delivered event IDs a catch-up
+// subscription remembers to drop events delivered twice around the switch
+// from history to live events.
+const dedupWindow = 1000
+
+// recentIDs is a fixed-size set of the most recently added IDs.
+type recentIDs struct {
+	ids  []string
+	next int
+	set  map[string]struct{}
+}
+
+func newRecentIDs(size int) *recentIDs {
+	return &recentIDs{ids: make([]string, size), set: make(map[string]struct{}, size)}
+}
+
+func (r *recentIDs) add(id string) {
+	if _, ok := r.set[id]; ok {
+		return
+	}
+	if old := r.ids[r.next]; old != "" {
+		delete(r.set, old)
+	}
+	r.ids[r.next] = id
+	r.set[id] = struct{}{}
+	r.next = (r.next + 1) % len(r.ids)
+}
+
+func (r *recentIDs) contains(id string) bool {
+	_, ok := r.set[id]
+	return ok
+}
+
+// CatchUpSubscription is a Subscription that first replays the stored
+// events and then delivers new events as they are committed.
+type CatchUpSubscription struct {
+	*Subscription
+
+	caughtUp chan struct{}
+}
+
+// CaughtUp returns a channel that is closed once all events stored when the
+// subscription started have been received from Events. A consumer that
+// handles each event before receiving the next therefore sees it only after
+// handling the last stored event. It stays open if the subscription ends
+// before.
+func (s *CatchUpSubscription) CaughtUp() <-chan struct{} {
+	return s.caughtUp
+}
+
+// Subscribe delivers the events of subject after the event with the ID
+// from, or all of them if from is empty: first the stored events, read with
+// a stream, then new events as they are committed, observed with
+// ObserveWithReconnect. Both phases re-establish a dropped connection
+// according to policy and resume after the last delivered event. The
+// observation resumes after the last replayed event, and events delivered twice around the switch are dropped,
+// so every event is delivered once and in order. Unlike Observe, Subscribe
+// does not read ahead of the consumer: each send completes only once the
+// event has been received, so CaughtUp is closed as soon as the last stored
+// event has been. A nil policy uses the defaults.
+//
+//	sub := client.Subscribe(ctx, "/orders", checkpoint, nil)
+//	defer sub.Close()
+//	caughtUp := sub.CaughtUp()
+//	for {
+//		select {
+//		case event, ok := <-sub.Events():
+//			if !ok {
+//				return sub.Err()
+//			}
+//			apply(event)
+//		case <-caughtUp:
+//			caughtUp = nil
+//			markReady()
+//		}
+//	}
+func (es *Genesisdb) Subscribe(ctx context.Context, subject, from string, policy *ReconnectPolicy) *CatchUpSubscription {
+	if policy == nil {
+		policy = &ReconnectPolicy{}
+	}
+	sub := &CatchUpSubscription{
+		Subscription: newSubscription(ctx, 0),
+		caughtUp:     make(chan struct{}),
+	}
+	sub.recent = newRecentIDs(dedupWindow)
+
+	go func() {
+		sub.finish(es.subscribe(sub.ctx, subject, from, policy, sub))
+	}()
+
+	return sub
+}
+
+func (es *Genesisdb) subscribe(ctx context.Context, subject, from string, policy *ReconnectPolicy, sub *CatchUpSubscription) error {
+	var options *StreamOptions
+	if from != "" {
+		options = &StreamOptions{LowerBound: from}
+	}
+
+	if err := es.replay(ctx, subject, options, policy, sub); err != nil {
+		return err
+	}
+
+	// Events are not buffered, so the consumer has received the last of them
+	close(sub.caughtUp)
+
+	// The observation resumes after the last delivered event, or starts
+	// after from if the history was empty
+	return es.observeWithReconnect(ctx, subject, options, policy, sub.Subscription)
+}
+
+// replay delivers the stored events of subject to sub. A stream that breaks
+// off is read again from after the last delivered event, waiting according
+// to policy like the observation does.
+func (es *Genesisdb) replay(ctx context.Context, subject string, options *StreamOptions, policy *ReconnectPolicy, sub *CatchUpSubscription) error {
+	attempt := 0
+	for {
+		before := sub.LastEventID()
+		it, err := es.StreamEventsIterator(ctx, subject, resumeOptions(options, before))
+		if err == nil {
+			err = sub.deliver(it)
+			if err == nil {
+				return nil
+			}
+			if it.invalid {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !reconnectable(err) {
+			return err
+		}
+
+		if sub.LastEventID() != before {
+			attempt = 0
+		}
+		attempt++
+		if err := policy.wait(ctx, attempt, err); err != nil {
+			return err
+		}
+	}
+}
+
+// deliver sends the events of it that have not been delivered yet and
+// closes it.
+func (s *CatchUpSubscription) deliver(it *EventIterator) error {
+	defer it.Close()
+
+	for it.Next() {
+		if s.duplicate(it.source) {
+			continue
+		}
+		if err := s.send(it.Event()); err != nil {
+			return err
+		}
+		if len(it.pending) == 0 {
+			s.delivered(it.source)
+		}
+	}
+	return it.Err()
+}
@@ -0,0 +1,177 @@
+package genesisdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecentIDs(t *testing.T) {
+	recent := newRecentIDs(2)
+	recent.add("1")
+	recent.add("2")
+	recent.add("2")
+	if !recent.contains("1") || !recent.contains("2") {
+		t.Fatal("Expected 1 and 2 to be contained")
+	}
+
+	recent.add("3")
+	if recent.contains("1") || !recent.contains("2") || !recent.contains("3") {
+		t.Errorf("Expected the oldest ID to be evicted, got %v", recent.ids)
+	}
+}
+
+func TestSubscribe_Mock(t *testing.T) {
+	history := []Event{
+		{ID: "1", Subject: "/orders", Type: "order-placed"},
+		{ID: "2", Subject: "/orders", Type: "order-placed"},
+		{ID: "3", Subject: "/orders", Type: "order-placed"},
+	}
+	live := make(chan Event, 1)
+	observed := make(chan *StreamOptions, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req StreamRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(200)
+
+		write := func(event Event) {
+			line, _ := json.Marshal(event)
+			w.Write(append(line, '\n'))
+			w.(http.Flusher).Flush()
+		}
+
+		switch r.URL.Path {
+		case "/api/v1/stream":
+			for _, event := range history {
+				if req.Options == nil || event.ID > req.Options.LowerBound {
+					write(event)
+				}
+			}
+		case "/api/v1/observe":
+			observed <- req.Options
+			// Overlap with the history, as after a race between the two
+			for _, event := range history[1:] {
+				write(event)
+			}
+			select {
+			case event := <-live:
+				write(event)
+			case <-r.Context().Done():
+				return
+			}
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"})
+
+	sub := client.Subscribe(context.Background(), "/orders", "1", nil)
+	defer sub.Close()
+
+	var ids []string
+	receive := func() {
+		select {
+		case event := <-sub.Events():
+			ids = append(ids, event.ID)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for event, received %v", ids)
+		}
+	}
+
+	receive()
+	receive()
+	select {
+	case <-sub.CaughtUp():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for caught up signal")
+	}
+
+	live <- Event{ID: "4", Subject: "/orders", Type: "order-placed"}
+	receive()
+
+	if len(ids) != 3 || ids[0] != "2" || ids[1] != "3" || ids[2] != "4" {
+		t.Errorf("Received %v, want [2 3 4]", ids)
+	}
+	if options := <-observed; options == nil || options.LowerBound != "3" || options.IncludeLowerBoundEvent {
+		t.Errorf("Observation started with %+v, want after 3", options)
+	}
+	if sub.LastEventID() != "4" {
+		t.Errorf("LastEventID() = %s, want 4", sub.LastEventID())
+	}
+}
+
+func TestSubscribe_HistoryReconnect(t *testing.T) {
+	history := []Event{
+		{ID: "1", Subject: "/orders", Type: "order-placed"},
+		{ID: "2", Subject: "/orders", Type: "order-placed"},
+		{ID: "3", Subject: "/orders", Type: "order-placed"},
+	}
+	streamed := make(chan *StreamOptions, 2)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req StreamRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(200)
+
+		switch r.URL.Path {
+		case "/api/v1/stream":
+			streamed <- req.Options
+			for _, event := range history {
+				if req.Options != nil && event.ID <= req.Options.LowerBound {
+					continue
+				}
+				line, _ := json.Marshal(event)
+				w.Write(append(line, '\n'))
+				w.(http.Flusher).Flush()
+				if req.Options == nil && event.ID == "2" {
+					// Break off the first stream in the middle
+					panic(http.ErrAbortHandler)
+				}
+			}
+		case "/api/v1/observe":
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"})
+
+	var reconnects []int
+	policy := &ReconnectPolicy{
+		InitialBackoff: time.Millisecond,
+		OnReconnect:    func(attempt int, err error) { reconnects = append(reconnects, attempt) },
+	}
+	sub := client.Subscribe(context.Background(), "/orders", "", policy)
+	defer sub.Close()
+
+	var ids []string
+	for len(ids) < len(history) {
+		select {
+		case event := <-sub.Events():
+			ids = append(ids, event.ID)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for event, received %v, error %v", ids, sub.Err())
+		}
+	}
+	select {
+	case <-sub.CaughtUp():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for caught up signal")
+	}
+
+	if ids[0] != "1" || ids[1] != "2" || ids[2] != "3" {
+		t.Errorf("Received %v, want [1 2 3]", ids)
+	}
+	if len(reconnects) != 1 || reconnects[0] != 1 {
+		t.Errorf("Reconnect attempts = %v, want [1]", reconnects)
+	}
+	<-streamed
+	if options := <-streamed; options == nil || options.LowerBound != "2" || options.IncludeLowerBoundEvent {
+		t.Errorf("Replay resumed with %+v, want after 2", options)
+	}
+}
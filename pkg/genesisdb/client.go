@@ -3,7 +3,9 @@ package genesisdb
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -20,8 +22,19 @@ type Config struct {
 }
 
 type Genesisdb struct {
-	config   *Config
-	client   *http.Client
+	config *Config
+	client *http.Client
+
+	transport      http.RoundTripper
+	defaultTimeout time.Duration
+	timeouts       map[Operation]time.Duration
+	userAgent      string
+	headers        http.Header
+	retryPolicy    *RetryPolicy
+	tokenSource    TokenSource
+	registry       *Registry
+	upcasters      *UpcasterChain
+	validator      EventValidator
 }
 
 type RFC3339Time time.Time
@@ -61,6 +74,22 @@ type Event struct {
 	DataContentType string                 `json:"datacontenttype,omitempty"`
 	SpecVersion     string                 `json:"specversion,omitempty"`
 	Options         map[string]interface{} `json:"options,omitempty"`
+
+	// rawData holds the data as received from the API, see DecodeData
+	rawData json.RawMessage
+	// resumeID is the ID of the received event, set on the last event
+	// derived from it, see ResumeID
+	resumeID string
+}
+
+// ResumeID returns the ID to resume a stream or observation after once e
+// has been processed: the ID of the event received from the API that e was
+// derived from. With upcasters, e may carry a made-up ID, or be one of
+// several events an event was split into. ResumeID is empty for all but the
+// last of those, since resuming after the received event would skip the
+// rest, and for events that were not received from the API.
+func (e Event) ResumeID() string {
+	return e.resumeID
 }
 
 type Precondition struct {
@@ -69,14 +98,64 @@ type Precondition struct {
 }
 
 type CommitRequest struct {
-	Events        []Event         `json:"events"`
-	Preconditions []Precondition  `json:"preconditions,omitempty"`
+	Events        []Event        `json:"events"`
+	Preconditions []Precondition `json:"preconditions,omitempty"`
+}
+
+// CommitOptions configures a commit made with CommitEventsWithOptions.
+type CommitOptions struct {
+	// Preconditions must all hold on the server for the commit to succeed.
+	Preconditions []Precondition
+	// StoreDataAsReference stores the data of every event separately from
+	// the event itself, so it can later be removed with EraseData (GDPR).
+	StoreDataAsReference bool
+}
+
+// withEventOption returns a copy of events with key set in the options of
+// each event. The options maps of events are left untouched.
+func withEventOption(events []Event, key string, value interface{}) []Event {
+	result := make([]Event, len(events))
+	for i, event := range events {
+		options := make(map[string]interface{}, len(event.Options)+1)
+		for k, v := range event.Options {
+			options[k] = v
+		}
+		options[key] = value
+		event.Options = options
+		result[i] = event
+	}
+	return result
 }
 
 type StreamOptions struct {
-	LowerBound            string `json:"lowerBound,omitempty"`
+	LowerBound             string `json:"lowerBound,omitempty"`
 	IncludeLowerBoundEvent bool   `json:"includeLowerBoundEvent,omitempty"`
-	LatestByEventType     string `json:"latestByEventType,omitempty"`
+	UpperBound             string `json:"upperBound,omitempty"`
+	IncludeUpperBoundEvent bool   `json:"includeUpperBoundEvent,omitempty"`
+	LatestByEventType      string `json:"latestByEventType,omitempty"`
+}
+
+// ErrInvalidStreamOptions is returned before any request is made when
+// StreamOptions describe an impossible range.
+var ErrInvalidStreamOptions = errors.New("invalid stream options")
+
+// Validate checks that the bounds describe a usable range: the Include flags
+// need their bound, and a range with equal bounds must include both ends.
+// A nil StreamOptions is valid.
+func (o *StreamOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.IncludeLowerBoundEvent && o.LowerBound == "" {
+		return fmt.Errorf("%w: IncludeLowerBoundEvent requires LowerBound", ErrInvalidStreamOptions)
+	}
+	if o.IncludeUpperBoundEvent && o.UpperBound == "" {
+		return fmt.Errorf("%w: IncludeUpperBoundEvent requires UpperBound", ErrInvalidStreamOptions)
+	}
+	if o.LowerBound != "" && o.LowerBound == o.UpperBound && !(o.IncludeLowerBoundEvent && o.IncludeUpperBoundEvent) {
+		return fmt.Errorf("%w: equal bounds must include both bound events", ErrInvalidStreamOptions)
+	}
+	return nil
 }
 
 type StreamRequest struct {
@@ -84,239 +163,333 @@ type StreamRequest struct {
 	Options *StreamOptions `json:"options,omitempty"`
 }
 
-func NewClient(config *Config) (*Genesisdb, error) {
+func NewClient(config *Config, opts ...Option) (*Genesisdb, error) {
 	if config.APIURL == "" {
 		return nil, fmt.Errorf("APIURL is required")
 	}
 	if config.APIVersion == "" {
 		return nil, fmt.Errorf("APIVersion is required")
 	}
-	if config.AuthToken == "" {
-		return nil, fmt.Errorf("AuthToken is required")
+
+	es := &Genesisdb{
+		config:    config,
+		client:    &http.Client{},
+		userAgent: defaultUserAgent,
+		headers:   make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(es)
+	}
+	if es.tokenSource == nil {
+		if config.AuthToken == "" {
+			return nil, fmt.Errorf("AuthToken is required")
+		}
+		es.tokenSource = StaticToken(config.AuthToken)
+	}
+	if es.transport != nil {
+		client := *es.client
+		client.Transport = es.transport
+		es.client = &client
 	}
 
-	return &Genesisdb{
-		config: config,
-		client: &http.Client{},
-	}, nil
+	return es, nil
 }
 
-func (es *Genesisdb) StreamEvents(subject string, options *StreamOptions) ([]Event, error) {
-	url := fmt.Sprintf("%s/api/%s/stream", strings.TrimRight(es.config.APIURL, "/"), es.config.APIVersion)
+func (es *Genesisdb) endpoint(path string) string {
+	return fmt.Sprintf("%s/api/%s/%s", strings.TrimRight(es.config.APIURL, "/"), es.config.APIVersion, path)
+}
 
-	requestBody := StreamRequest{
-		Subject: subject,
-		Options: options,
+// newRequest builds an authenticated request for the given API path. A nil
+// body produces a request without payload, anything else is sent as JSON.
+func (es *Genesisdb) newRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	token, err := es.tokenSource.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting auth token: %w", err)
 	}
 
-	requestBodyBytes, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling request: %w", err)
+	var reader io.Reader
+	if body != nil {
+		requestBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling request: %w", err)
+		}
+		reader = bytes.NewReader(requestBody)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBodyBytes))
+	req, err := http.NewRequestWithContext(ctx, method, es.endpoint(path), reader)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", es.config.AuthToken))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/x-ndjson")
-	req.Header.Set("User-Agent", "genesisdb-sdk")
+	for key, values := range es.headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", es.userAgent)
 
+	return req, nil
+}
+
+// do sends the request, retrying it according to the client's retry policy,
+// and returns the response if the API answered with 200 OK, or an *APIError
+// otherwise. The caller is responsible for closing the response body.
+func (es *Genesisdb) do(req *http.Request) (*http.Response, error) {
+	return es.doWithRetry(req, nil)
+}
+
+// send makes a single attempt at req with the semantics of do.
+func (es *Genesisdb) send(req *http.Request) (*http.Response, error) {
 	resp, err := es.client.Do(req)
 	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(bodyBytes),
+			Endpoint:   req.URL.Path,
+			Header:     resp.Header,
+		}
 	}
 
-	var events []Event
-	scanner := bufio.NewScanner(resp.Body)
+	return resp, nil
+}
+
+// scanLines calls fn for every non-empty line of an NDJSON body and stops
+// as soon as ctx is done.
+func scanLines(ctx context.Context, r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
 
-		var event Event
-		if err := json.Unmarshal([]byte(line), &event); err != nil {
-			return nil, fmt.Errorf("error parsing event JSON: %w", err)
+		if err := fn(line); err != nil {
+			return err
 		}
+	}
 
-		if event.ID == "" {
-			event.ID = uuid.New().String()
-		}
-		if event.Source == "" {
-			event.Source = es.config.APIURL
-		}
-		if event.DataContentType == "" {
-			event.DataContentType = "application/json"
-		}
-		if event.SpecVersion == "" {
-			event.SpecVersion = "1.0"
-		}
-		if event.Time == RFC3339Time(time.Time{}) {
-			now := time.Now().UTC()
-			event.Time = RFC3339Time(now)
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
 		}
+		return fmt.Errorf("error reading response: %w", err)
+	}
+
+	return ctx.Err()
+}
 
-		events = append(events, event)
+func (es *Genesisdb) populateDefaults(event *Event) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Source == "" {
+		event.Source = es.config.APIURL
+	}
+	if event.DataContentType == "" {
+		event.DataContentType = "application/json"
+	}
+	if event.SpecVersion == "" {
+		event.SpecVersion = "1.0"
 	}
+	if event.Time == RFC3339Time(time.Time{}) {
+		now := time.Now().UTC()
+		event.Time = RFC3339Time(now)
+	}
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading response: %w", err)
+func (es *Genesisdb) StreamEvents(subject string, options *StreamOptions) ([]Event, error) {
+	return es.StreamEventsContext(context.Background(), subject, options)
+}
+
+// StreamEventsContext is like StreamEvents but aborts the request and stops
+// reading the stream once ctx is done.
+func (es *Genesisdb) StreamEventsContext(ctx context.Context, subject string, options *StreamOptions) ([]Event, error) {
+	it, err := es.StreamEventsIterator(ctx, subject, options)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var events []Event
+	for it.Next() {
+		events = append(events, it.Event())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
 	}
 
 	return events, nil
 }
 
 func (es *Genesisdb) CommitEvents(events []Event) error {
-	return es.CommitEventsWithPreconditions(events, nil)
+	return es.CommitEventsContext(context.Background(), events)
+}
+
+func (es *Genesisdb) CommitEventsContext(ctx context.Context, events []Event) error {
+	return es.CommitEventsWithPreconditionsContext(ctx, events, nil)
 }
 
 func (es *Genesisdb) CommitEventsWithPreconditions(events []Event, preconditions []Precondition) error {
-	return es.CommitEventsWithOptions(events, preconditions)
+	return es.CommitEventsWithPreconditionsContext(context.Background(), events, preconditions)
 }
 
-func (es *Genesisdb) CommitEventsWithOptions(events []Event, preconditions []Precondition) error {
-	url := fmt.Sprintf("%s/api/%s/commit", strings.TrimRight(es.config.APIURL, "/"), es.config.APIVersion)
+func (es *Genesisdb) CommitEventsWithPreconditionsContext(ctx context.Context, events []Event, preconditions []Precondition) error {
+	return es.CommitEventsWithOptionsContext(ctx, events, &CommitOptions{Preconditions: preconditions})
+}
 
-	for i := range events {
-		if events[i].ID == "" {
-			events[i].ID = uuid.New().String()
-		}
-		if events[i].Source == "" {
-			events[i].Source = es.config.APIURL
-		}
-		if events[i].DataContentType == "" {
-			events[i].DataContentType = "application/json"
-		}
-		if events[i].SpecVersion == "" {
-			events[i].SpecVersion = "1.0"
-		}
-		if events[i].Time == RFC3339Time(time.Time{}) {
-			now := time.Now().UTC()
-			events[i].Time = RFC3339Time(now)
+func (es *Genesisdb) CommitEventsWithOptions(events []Event, options *CommitOptions) error {
+	return es.CommitEventsWithOptionsContext(context.Background(), events, options)
+}
+
+func (es *Genesisdb) CommitEventsWithOptionsContext(ctx context.Context, events []Event, options *CommitOptions) error {
+	if options == nil {
+		options = &CommitOptions{}
+	}
+	preconditions := options.Preconditions
+	if err := validatePreconditions(preconditions); err != nil {
+		return err
+	}
+
+	if es.validator != nil {
+		if err := es.validator.Validate(events); err != nil {
+			return err
 		}
 	}
 
+	ctx, cancel := es.withTimeout(ctx, OperationCommit)
+	defer cancel()
+
+	for i := range events {
+		es.populateDefaults(&events[i])
+	}
+
 	commitRequest := CommitRequest{
 		Events: events,
 	}
+	if options.StoreDataAsReference {
+		commitRequest.Events = withEventOption(events, "storeDataAsReference", true)
+	}
 	if preconditions != nil {
 		commitRequest.Preconditions = preconditions
 	}
 
-	requestBody, err := json.Marshal(commitRequest)
+	req, err := es.newRequest(ctx, "POST", "commit", commitRequest)
 	if err != nil {
-		return fmt.Errorf("error marshaling request: %w", err)
+		return err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+	resp, err := es.doWithRetry(req, func() (bool, error) {
+		return es.committed(ctx, events)
+	})
+	if errors.Is(err, errAlreadyApplied) {
+		return nil
 	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", es.config.AuthToken))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "genesisdb-sdk")
-
-	resp, err := es.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed {
+			apiErr.FailedPreconditions = parsePreconditionFailures(apiErr.Body, preconditions)
+		}
+		return err
 	}
+	resp.Body.Close()
 
 	return nil
 }
 
-func (es *Genesisdb) EraseData(subject string) error {
-	url := fmt.Sprintf("%s/api/%s/erase", strings.TrimRight(es.config.APIURL, "/"), es.config.APIVersion)
-
-	requestBody, err := json.Marshal(map[string]string{"subject": subject})
-	if err != nil {
-		return fmt.Errorf("error marshaling request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+// committed reports whether events were already stored by an earlier commit
+// attempt. Commits are atomic, so it is enough to look up the first event by
+// its client-assigned ID.
+func (es *Genesisdb) committed(ctx context.Context, events []Event) (bool, error) {
+	if len(events) == 0 {
+		return false, nil
 	}
+	return es.EventExists(ctx, events[0].Subject, events[0].ID)
+}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", es.config.AuthToken))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "genesisdb-sdk")
-
-	resp, err := es.client.Do(req)
+// EventExists reports whether the event with the given ID is stored under
+// subject. The event is looked up as stored, without upcasters or a
+// registry, which could change its ID or skip it. Together with
+// client-assigned event IDs it tells whether a commit whose outcome is
+// unknown was applied. An unknown ID is expected to select no events when
+// used as the lower bound of a stream.
+func (es *Genesisdb) EventExists(ctx context.Context, subject, id string) (bool, error) {
+	it, err := es.StreamEventsIterator(ctx, subject, &StreamOptions{
+		LowerBound:             id,
+		IncludeLowerBoundEvent: true,
+	})
 	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
+		return false, err
 	}
-	defer resp.Body.Close()
+	defer it.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
+	it.raw = true
+	for it.Next() {
+		if it.Event().ID == id {
+			return true, nil
+		}
 	}
+	return false, it.Err()
+}
 
-	return nil
+func (es *Genesisdb) EraseData(subject string) error {
+	return es.EraseDataContext(context.Background(), subject)
 }
 
-func (es *Genesisdb) Q(query string) ([]interface{}, error) {
-	url := fmt.Sprintf("%s/api/%s/q", strings.TrimRight(es.config.APIURL, "/"), es.config.APIVersion)
+func (es *Genesisdb) EraseDataContext(ctx context.Context, subject string) error {
+	ctx, cancel := es.withTimeout(ctx, OperationErase)
+	defer cancel()
 
-	requestBody, err := json.Marshal(map[string]string{"query": query})
+	req, err := es.newRequest(ctx, "POST", "erase", map[string]string{"subject": subject})
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling request: %w", err)
+		return err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	resp, err := es.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return err
 	}
+	resp.Body.Close()
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", es.config.AuthToken))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/x-ndjson")
-	req.Header.Set("User-Agent", "genesisdb-sdk")
+	return nil
+}
 
-	resp, err := es.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
-	}
-	defer resp.Body.Close()
+// Q executes a GDBQL query. Values given in params are bound to the $name
+// placeholders of query, see BindParams.
+func (es *Genesisdb) Q(query string, params ...Params) ([]interface{}, error) {
+	return es.QContext(context.Background(), query, params...)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
+// QContext is like Q but aborts the request and stops reading results once
+// ctx is done.
+func (es *Genesisdb) QContext(ctx context.Context, query string, params ...Params) ([]interface{}, error) {
+	it, err := es.QIterator(ctx, query, params...)
+	if err != nil {
+		return nil, err
 	}
+	defer it.Close()
 
 	var results []interface{}
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-
-		var result interface{}
-		if err := json.Unmarshal([]byte(line), &result); err != nil {
-			return nil, fmt.Errorf("error parsing result JSON: %w", err)
-		}
-		results = append(results, result)
+	for it.Next() {
+		results = append(results, it.Result())
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading response: %w", err)
+	if err := it.Err(); err != nil {
+		return nil, err
 	}
 
 	return results, nil
@@ -326,65 +499,52 @@ func (es *Genesisdb) Q(query string) ([]interface{}, error) {
 // query: The query string to execute
 // Returns: Array of query results and any error
 // Example:
-//   results, err := client.QueryEvents(`FROM e IN events WHERE e.type == "io.genesisdb.app.customer-added" ORDER BY e.time DESC TOP 20 PROJECT INTO { subject: e.subject, firstName: e.data.firstName }`)
-func (es *Genesisdb) QueryEvents(query string) ([]interface{}, error) {
-	return es.Q(query)
+//
+//	results, err := client.QueryEvents(`FROM e IN events WHERE e.type == "io.genesisdb.app.customer-added" ORDER BY e.time DESC TOP 20 PROJECT INTO { subject: e.subject, firstName: e.data.firstName }`)
+func (es *Genesisdb) QueryEvents(query string, params ...Params) ([]interface{}, error) {
+	return es.Q(query, params...)
 }
 
-func (es *Genesisdb) Ping() (string, error) {
-	url := fmt.Sprintf("%s/api/%s/status/ping", strings.TrimRight(es.config.APIURL, "/"), es.config.APIVersion)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", es.config.AuthToken))
-	req.Header.Set("User-Agent", "genesisdb-sdk")
+func (es *Genesisdb) QueryEventsContext(ctx context.Context, query string, params ...Params) ([]interface{}, error) {
+	return es.QContext(ctx, query, params...)
+}
 
-	resp, err := es.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("error making request: %w", err)
-	}
-	defer resp.Body.Close()
+func (es *Genesisdb) Ping() (string, error) {
+	return es.PingContext(context.Background())
+}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
-	}
+func (es *Genesisdb) PingContext(ctx context.Context) (string, error) {
+	return es.getStatus(ctx, OperationPing)
+}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading response: %w", err)
-	}
+func (es *Genesisdb) Audit() (string, error) {
+	return es.AuditContext(context.Background())
+}
 
-	return string(bodyBytes), nil
+func (es *Genesisdb) AuditContext(ctx context.Context) (string, error) {
+	return es.getStatus(ctx, OperationAudit)
 }
 
-func (es *Genesisdb) Audit() (string, error) {
-	url := fmt.Sprintf("%s/api/%s/status/audit", strings.TrimRight(es.config.APIURL, "/"), es.config.APIVersion)
+func (es *Genesisdb) getStatus(ctx context.Context, op Operation) (string, error) {
+	ctx, cancel := es.withTimeout(ctx, op)
+	defer cancel()
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := es.newRequest(ctx, "GET", string(op), nil)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return "", err
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", es.config.AuthToken))
-	req.Header.Set("User-Agent", "genesisdb-sdk")
-
-	resp, err := es.client.Do(req)
+	resp, err := es.do(req)
 	if err != nil {
-		return "", fmt.Errorf("error making request: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
-	}
-
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
 		return "", fmt.Errorf("error reading response: %w", err)
 	}
 
@@ -392,105 +552,123 @@ func (es *Genesisdb) Audit() (string, error) {
 }
 
 func (es *Genesisdb) ObserveEvents(subject string, options *StreamOptions) (<-chan Event, <-chan error) {
-	eventChan := make(chan Event, 100)
-	errorChan := make(chan error, 1)
-
-	go func() {
-		defer close(eventChan)
-		defer close(errorChan)
+	return es.ObserveEventsContext(context.Background(), subject, options)
+}
 
-		url := fmt.Sprintf("%s/api/%s/observe", strings.TrimRight(es.config.APIURL, "/"), es.config.APIVersion)
+// legacySendTimeout is how long ObserveEvents waits for an event to be
+// received before it gives up.
+const legacySendTimeout = 5 * time.Second
+
+// ObserveEventsContext is like ObserveEvents but closes the connection and
+// both channels once ctx is done. Unlike Observe, both end the observation
+// with an error if an event is not received within 5 seconds.
+func (es *Genesisdb) ObserveEventsContext(ctx context.Context, subject string, options *StreamOptions) (<-chan Event, <-chan error) {
+	sub := newSubscription(ctx, subscriptionBuffer)
+	sub.sendTimeout = legacySendTimeout
+	es.startObserve(sub, subject, options)
+	return sub.Events(), sub.Errors()
+}
 
-		requestBody := StreamRequest{
-			Subject: subject,
-			Options: options,
-		}
+// Observe starts observing events for subject and returns a handle to the
+// running observation. Call Close to stop it.
+func (es *Genesisdb) Observe(ctx context.Context, subject string, options *StreamOptions) *Subscription {
+	sub := newSubscription(ctx, subscriptionBuffer)
+	es.startObserve(sub, subject, options)
+	return sub
+}
 
-		requestBodyBytes, err := json.Marshal(requestBody)
-		if err != nil {
-			errorChan <- fmt.Errorf("error marshaling request: %w", err)
-			return
+// startObserve runs the observation that delivers to sub in the background.
+func (es *Genesisdb) startObserve(sub *Subscription, subject string, options *StreamOptions) {
+	go func() {
+		for {
+			err := es.observe(sub.ctx, subject, resumeOptions(options, sub.LastEventID()), sub)
+			if !errors.Is(err, errTokenRotated) {
+				sub.finish(err)
+				return
+			}
 		}
+	}()
+}
 
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBodyBytes))
-		if err != nil {
-			errorChan <- fmt.Errorf("error creating request: %w", err)
-			return
-		}
+// observe holds one /observe connection open and delivers its events to sub
+// until the server ends the stream or an error occurs. It returns
+// errTokenRotated if the connection was closed because the token changed.
+func (es *Genesisdb) observe(parent context.Context, subject string, options *StreamOptions, sub *Subscription) (err error) {
+	if err := options.Validate(); err != nil {
+		return err
+	}
 
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", es.config.AuthToken))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Accept", "application/x-ndjson")
-		req.Header.Set("User-Agent", "genesisdb-sdk")
+	ctx, cancel := es.withTimeout(parent, OperationObserve)
+	defer cancel()
 
-		resp, err := es.client.Do(req)
-		if err != nil {
-			errorChan <- fmt.Errorf("error making request: %w", err)
-			return
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	defer cancelCause(nil)
+	defer es.watchTokenRotation(ctx, cancelCause)()
+	defer func() {
+		if parent.Err() == nil && errors.Is(context.Cause(ctx), errTokenRotated) {
+			err = errTokenRotated
 		}
-		defer resp.Body.Close()
+	}()
 
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			errorChan <- fmt.Errorf("API error: %s - %s", resp.Status, string(bodyBytes))
-			return
-		}
+	requestBody := StreamRequest{
+		Subject: subject,
+		Options: options,
+	}
 
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line == "" {
-				continue
-			}
+	req, err := es.newRequest(ctx, "POST", "observe", requestBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
 
-			jsonStr := line
-			if strings.HasPrefix(line, "data: ") {
-				jsonStr = line[6:]
-			}
+	resp, err := es.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-			// Check if this is an empty payload object with only one key
-			var jsonMap map[string]interface{}
-			if err := json.Unmarshal([]byte(jsonStr), &jsonMap); err == nil {
-				if payload, ok := jsonMap["payload"].(string); ok && payload == "" && len(jsonMap) == 1 {
-					continue
-				}
-			}
+	err = scanLines(ctx, resp.Body, func(line string) error {
+		jsonStr := line
+		if strings.HasPrefix(line, "data: ") {
+			jsonStr = line[6:]
+		}
 
-			var event Event
-			if err := json.Unmarshal([]byte(jsonStr), &event); err != nil {
-				errorChan <- fmt.Errorf("error parsing event JSON: %w", err)
-				continue
+		// Check if this is an empty payload object with only one key
+		var jsonMap map[string]interface{}
+		if err := json.Unmarshal([]byte(jsonStr), &jsonMap); err == nil {
+			if payload, ok := jsonMap["payload"].(string); ok && payload == "" && len(jsonMap) == 1 {
+				return nil
 			}
+		}
 
-			if event.ID == "" {
-				event.ID = uuid.New().String()
-			}
-			if event.Source == "" {
-				event.Source = es.config.APIURL
-			}
-			if event.DataContentType == "" {
-				event.DataContentType = "application/json"
-			}
-			if event.SpecVersion == "" {
-				event.SpecVersion = "1.0"
-			}
-			if event.Time == RFC3339Time(time.Time{}) {
-				now := time.Now().UTC()
-				event.Time = RFC3339Time(now)
-			}
+		var event Event
+		if err := json.Unmarshal([]byte(jsonStr), &event); err != nil {
+			sub.sendError(fmt.Errorf("error parsing event JSON: %w", err))
+			return nil
+		}
 
-			select {
-			case eventChan <- event:
-			case <-time.After(5 * time.Second):
-				errorChan <- fmt.Errorf("timeout sending event to channel")
-				return
-			}
+		// A resumed connection must not repeat the event it resumed after
+		if sub.duplicate(event.ID) {
+			return nil
 		}
 
-		if err := scanner.Err(); err != nil {
-			errorChan <- fmt.Errorf("error reading response: %w", err)
+		events, err := es.prepareEvents(event)
+		if err != nil {
+			sub.sendError(err)
+			return nil
 		}
-	}()
+		for _, prepared := range events {
+			if err := sub.send(prepared); err != nil {
+				return err
+			}
+		}
+
+		sub.delivered(event.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	return eventChan, errorChan
+	return ErrStreamEnded
 }
@@ -2,7 +2,9 @@ package genesisdb
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -177,6 +179,67 @@ func TestStreamEvents_Mock(t *testing.T) {
 		}
 	})
 
+	t.Run("With CommitOptions", func(t *testing.T) {
+		mockTransport := &mockRoundTripper{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				var commitReq CommitRequest
+				json.Unmarshal(body, &commitReq)
+
+				for _, event := range commitReq.Events {
+					if event.Options["storeDataAsReference"] != true {
+						t.Errorf("Expected storeDataAsReference in event options, got %v", event.Options)
+					}
+				}
+				if commitReq.Events[1].Options["custom"] != "kept" {
+					t.Errorf("Expected existing event options to be kept, got %v", commitReq.Events[1].Options)
+				}
+				if len(commitReq.Preconditions) != 1 || commitReq.Preconditions[0].Type != "isSubjectNew" {
+					t.Errorf("Unexpected preconditions: %v", commitReq.Preconditions)
+				}
+
+				return &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(strings.NewReader("")),
+				}, nil
+			},
+		}
+
+		client, _ := NewClient(config)
+		client.client.Transport = mockTransport
+
+		events := []Event{
+			{
+				Source:  "test",
+				Subject: "/user/456",
+				Type:    "test.event",
+				Data:    map[string]interface{}{"email": "john.doe@example.com"},
+			},
+			{
+				Source:  "test",
+				Subject: "/user/456",
+				Type:    "test.event",
+				Data:    map[string]interface{}{"email": "john.doe@example.com"},
+				Options: map[string]interface{}{"custom": "kept"},
+			},
+		}
+
+		options := &CommitOptions{
+			Preconditions: []Precondition{
+				{Type: "isSubjectNew", Payload: map[string]interface{}{"subject": "/user/456"}},
+			},
+			StoreDataAsReference: true,
+		}
+
+		err := client.CommitEventsWithOptions(events, options)
+		if err != nil {
+			t.Fatalf("CommitEventsWithOptions() error = %v", err)
+		}
+		if _, ok := events[1].Options["storeDataAsReference"]; ok {
+			t.Error("CommitEventsWithOptions() should not modify the caller's event options")
+		}
+	})
+
 	t.Run("API error", func(t *testing.T) {
 		mockTransport := &mockRoundTripper{
 			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
@@ -904,3 +967,108 @@ func TestRFC3339Time_Time(t *testing.T) {
 }
 
 
+
+func TestContext_Mock(t *testing.T) {
+	config := &Config{
+		APIURL:     "http://localhost:8080",
+		APIVersion: "v1",
+		AuthToken:  "test-token",
+	}
+
+	t.Run("Canceled before request", func(t *testing.T) {
+		mockTransport := &mockRoundTripper{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				if err := req.Context().Err(); err != nil {
+					return nil, err
+				}
+				t.Error("Request should not be sent with a canceled context")
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+			},
+		}
+
+		client, _ := NewClient(config)
+		client.client.Transport = mockTransport
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := client.StreamEventsContext(ctx, "/test", nil); !errors.Is(err, context.Canceled) {
+			t.Errorf("StreamEventsContext() error = %v, want context.Canceled", err)
+		}
+		if err := client.CommitEventsContext(ctx, []Event{{Subject: "/test", Type: "test.event"}}); !errors.Is(err, context.Canceled) {
+			t.Errorf("CommitEventsContext() error = %v, want context.Canceled", err)
+		}
+		if err := client.EraseDataContext(ctx, "/test"); !errors.Is(err, context.Canceled) {
+			t.Errorf("EraseDataContext() error = %v, want context.Canceled", err)
+		}
+		if _, err := client.QContext(ctx, "FROM e IN events"); !errors.Is(err, context.Canceled) {
+			t.Errorf("QContext() error = %v, want context.Canceled", err)
+		}
+		if _, err := client.PingContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("PingContext() error = %v, want context.Canceled", err)
+		}
+		if _, err := client.AuditContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("AuditContext() error = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("Deadline stops stream mid-body", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			eventJSON, _ := json.Marshal(Event{ID: "1", Subject: "/test", Type: "test.event"})
+			w.WriteHeader(200)
+			w.Write([]byte(string(eventJSON) + "\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := client.StreamEventsContext(ctx, "/test", nil)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("StreamEventsContext() error = %v, want context.DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("Cancel closes observe channels", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			eventJSON, _ := json.Marshal(Event{ID: "1", Subject: "/test", Type: "test.event"})
+			w.WriteHeader(200)
+			w.Write([]byte(string(eventJSON) + "\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		eventChan, errorChan := client.ObserveEventsContext(ctx, "/test", nil)
+
+		select {
+		case <-eventChan:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for event")
+		}
+
+		cancel()
+
+		select {
+		case _, ok := <-eventChan:
+			if ok {
+				t.Error("Expected event channel to be closed")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for event channel to close")
+		}
+		for range errorChan {
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package genesisdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables read by
+// ConfigFromEnv unless WithEnvPrefix is given.
+const DefaultEnvPrefix = "GENESISDB_"
+
+// EnvOption customizes how ConfigFromEnv and NewClientFromEnv read the
+// environment.
+type EnvOption func(*envSettings)
+
+type envSettings struct {
+	prefix        string
+	files         []string
+	clientOptions []Option
+}
+
+// WithEnvPrefix reads PREFIX + API_URL, PREFIX + API_VERSION and so on
+// instead of the GENESISDB_ variables.
+func WithEnvPrefix(prefix string) EnvOption {
+	return func(s *envSettings) {
+		s.prefix = prefix
+	}
+}
+
+// WithEnvFile reads values from a .env file at path as a fallback for
+// variables that are not set in the environment. A missing file is ignored.
+func WithEnvFile(path string) EnvOption {
+	return func(s *envSettings) {
+		s.files = append(s.files, path)
+	}
+}
+
+// WithClientOptions passes opts to NewClient when the client is created by
+// NewClientFromEnv. ConfigFromEnv ignores them.
+func WithClientOptions(opts ...Option) EnvOption {
+	return func(s *envSettings) {
+		s.clientOptions = append(s.clientOptions, opts...)
+	}
+}
+
+// ConfigFromEnv builds a Config from the environment variables
+// GENESISDB_API_URL, GENESISDB_API_VERSION and GENESISDB_AUTH_TOKEN. Instead
+// of GENESISDB_AUTH_TOKEN, GENESISDB_AUTH_TOKEN_FILE may name a file holding
+// the token, such as a mounted Kubernetes secret. The returned error lists
+// every missing value.
+func ConfigFromEnv(opts ...EnvOption) (*Config, error) {
+	config, _, err := configFromEnv(newEnvSettings(opts))
+	return config, err
+}
+
+func newEnvSettings(opts []EnvOption) *envSettings {
+	settings := &envSettings{prefix: DefaultEnvPrefix}
+	for _, opt := range opts {
+		opt(settings)
+	}
+	return settings
+}
+
+// configFromEnv builds the Config described by settings and returns the
+// path of the token file it was read from, if any.
+func configFromEnv(settings *envSettings) (*Config, string, error) {
+	fileValues := make(map[string]string)
+	for _, path := range settings.files {
+		values, err := readEnvFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		for key, value := range values {
+			if _, ok := fileValues[key]; !ok {
+				fileValues[key] = value
+			}
+		}
+	}
+
+	lookup := func(name string) string {
+		key := settings.prefix + name
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return fileValues[key]
+	}
+
+	config := &Config{
+		APIURL:     lookup("API_URL"),
+		APIVersion: lookup("API_VERSION"),
+		AuthToken:  lookup("AUTH_TOKEN"),
+	}
+
+	var (
+		errs      []error
+		tokenFile string
+	)
+	if config.APIURL == "" {
+		errs = append(errs, fmt.Errorf("%sAPI_URL is required", settings.prefix))
+	}
+	if config.APIVersion == "" {
+		errs = append(errs, fmt.Errorf("%sAPI_VERSION is required", settings.prefix))
+	}
+	if config.AuthToken == "" {
+		if tokenFile = lookup("AUTH_TOKEN_FILE"); tokenFile != "" {
+			token, err := os.ReadFile(tokenFile)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error reading %sAUTH_TOKEN_FILE: %w", settings.prefix, err))
+			} else if config.AuthToken = strings.TrimSpace(string(token)); config.AuthToken == "" {
+				errs = append(errs, fmt.Errorf("%sAUTH_TOKEN_FILE %s is empty", settings.prefix, tokenFile))
+			}
+		} else {
+			errs = append(errs, fmt.Errorf("%sAUTH_TOKEN or %sAUTH_TOKEN_FILE is required", settings.prefix, settings.prefix))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, "", errors.Join(errs...)
+	}
+
+	return config, tokenFile, nil
+}
+
+// NewClientFromEnv creates a client from the environment like
+// ConfigFromEnv, falling back to a .env file in the working directory after
+// the files given with WithEnvFile. Options for the client are passed with
+// WithClientOptions.
+//
+// A token read from GENESISDB_AUTH_TOKEN_FILE is re-read when the API
+// rejects it, so a rotated Kubernetes secret is picked up without a restart.
+// The file is not polled, so the client owns no goroutine and needs no
+// closing; to also pick up changes before the old token is rejected, pass a
+// polling FileTokenSource with WithClientOptions and close it when done.
+func NewClientFromEnv(opts ...EnvOption) (*Genesisdb, error) {
+	settings := newEnvSettings(append(append([]EnvOption(nil), opts...), WithEnvFile(".env")))
+	config, tokenFile, err := configFromEnv(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOptions := settings.clientOptions
+	if tokenFile != "" {
+		source, err := NewFileTokenSource(tokenFile, 0)
+		if err != nil {
+			return nil, err
+		}
+		// A token source given explicitly still takes precedence
+		clientOptions = append([]Option{WithTokenSource(source)}, clientOptions...)
+	}
+	return NewClient(config, clientOptions...)
+}
+
+// readEnvFile parses KEY=VALUE lines from a .env file. Blank lines, comments
+// and an optional "export " prefix are skipped, and values may be quoted.
+// A missing file yields no values.
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error opening env file: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading env file: %w", err)
+	}
+
+	return values, nil
+}
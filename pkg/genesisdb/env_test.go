@@ -0,0 +1,112 @@
+package genesisdb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("Environment variables", func(t *testing.T) {
+		t.Setenv("GENESISDB_API_URL", "http://localhost:8080")
+		t.Setenv("GENESISDB_API_VERSION", "v1")
+		t.Setenv("GENESISDB_AUTH_TOKEN", "secret")
+
+		config, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("ConfigFromEnv() error = %v", err)
+		}
+		if config.APIURL != "http://localhost:8080" || config.APIVersion != "v1" || config.AuthToken != "secret" {
+			t.Errorf("Unexpected config: %+v", config)
+		}
+	})
+
+	t.Run("Custom prefix, env file and token file", func(t *testing.T) {
+		dir := t.TempDir()
+		tokenFile := filepath.Join(dir, "token")
+		os.WriteFile(tokenFile, []byte("from-file\n"), 0o600)
+		envFile := filepath.Join(dir, ".env")
+		os.WriteFile(envFile, []byte(strings.Join([]string{
+			"# comment",
+			"export MYAPP_API_URL=\"http://from-file:8080\"",
+			"MYAPP_API_VERSION=v1",
+			"MYAPP_AUTH_TOKEN_FILE=" + tokenFile,
+		}, "\n")), 0o600)
+		t.Setenv("MYAPP_API_URL", "http://from-env:8080")
+
+		config, err := ConfigFromEnv(WithEnvPrefix("MYAPP_"), WithEnvFile(envFile))
+		if err != nil {
+			t.Fatalf("ConfigFromEnv() error = %v", err)
+		}
+		if config.APIURL != "http://from-env:8080" {
+			t.Errorf("APIURL = %s, environment should take precedence over env file", config.APIURL)
+		}
+		if config.APIVersion != "v1" {
+			t.Errorf("APIVersion = %s, want v1 from env file", config.APIVersion)
+		}
+		if config.AuthToken != "from-file" {
+			t.Errorf("AuthToken = %q, want token from file", config.AuthToken)
+		}
+	})
+
+	t.Run("Missing values", func(t *testing.T) {
+		_, err := ConfigFromEnv(WithEnvPrefix("MISSING_"), WithEnvFile(filepath.Join(t.TempDir(), ".env")))
+		if err == nil {
+			t.Fatal("ConfigFromEnv() should return error")
+		}
+		for _, want := range []string{"MISSING_API_URL is required", "MISSING_API_VERSION is required", "MISSING_AUTH_TOKEN or MISSING_AUTH_TOKEN_FILE is required"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Error %q should contain %q", err, want)
+			}
+		}
+	})
+
+	t.Run("Unreadable token file", func(t *testing.T) {
+		t.Setenv("GENESISDB_API_URL", "http://localhost:8080")
+		t.Setenv("GENESISDB_API_VERSION", "v1")
+		t.Setenv("GENESISDB_AUTH_TOKEN", "")
+		t.Setenv("GENESISDB_AUTH_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		if _, err := NewClientFromEnv(); err == nil || !strings.Contains(err.Error(), "GENESISDB_AUTH_TOKEN_FILE") {
+			t.Errorf("NewClientFromEnv() error = %v, want token file error", err)
+		}
+	})
+	t.Run("Client rereads rotated token file", func(t *testing.T) {
+		valid := "Bearer first"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Tenant") != "billing" {
+				t.Errorf("X-Tenant = %q, want client option applied", r.Header.Get("X-Tenant"))
+			}
+			if r.Header.Get("Authorization") != valid {
+				w.WriteHeader(401)
+				return
+			}
+			w.WriteHeader(200)
+		}))
+		defer server.Close()
+
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		os.WriteFile(tokenFile, []byte("first\n"), 0o600)
+		t.Setenv("MYAPP_API_URL", server.URL)
+		t.Setenv("MYAPP_API_VERSION", "v1")
+		t.Setenv("MYAPP_AUTH_TOKEN_FILE", tokenFile)
+
+		client, err := NewClientFromEnv(WithEnvPrefix("MYAPP_"), WithClientOptions(WithHeader("X-Tenant", "billing")))
+		if err != nil {
+			t.Fatalf("NewClientFromEnv() error = %v", err)
+		}
+		if _, err := client.Ping(); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+
+		// The secret is rotated and the old token is rejected from now on
+		os.WriteFile(tokenFile, []byte("second\n"), 0o600)
+		valid = "Bearer second"
+		if _, err := client.Ping(); err != nil {
+			t.Fatalf("Ping() after rotation error = %v", err)
+		}
+	})
+}
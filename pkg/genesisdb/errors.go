@@ -0,0 +1,138 @@
+package genesisdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+var (
+	// ErrPreconditionFailed matches API errors with status 412, returned when
+	// a commit precondition did not hold.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnauthorized matches API errors with status 401 or 403.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound matches API errors with status 404.
+	ErrNotFound = errors.New("not found")
+	// ErrServerUnavailable matches API errors with status 502, 503 or 504.
+	ErrServerUnavailable = errors.New("server unavailable")
+)
+
+// APIError is returned when the API answers with a status other than 200 OK.
+// Use errors.Is with the sentinel errors above to check the kind of failure.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	Endpoint   string
+	Header     http.Header
+
+	// FailedPreconditions lists the preconditions that did not hold when a
+	// commit was rejected with status 412, as far as the server reported them.
+	FailedPreconditions []PreconditionFailure
+}
+
+// PreconditionFailure describes a precondition that made a commit fail.
+type PreconditionFailure struct {
+	Precondition Precondition
+	Reason       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error: %s - %s", e.Status, e.Body)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrServerUnavailable:
+		return e.StatusCode == http.StatusBadGateway ||
+			e.StatusCode == http.StatusServiceUnavailable ||
+			e.StatusCode == http.StatusGatewayTimeout
+	}
+	return false
+}
+
+// preconditionFailureBody covers the shapes in which the server reports
+// failed preconditions.
+type preconditionFailureBody struct {
+	Message             string                   `json:"message"`
+	Error               string                   `json:"error"`
+	Preconditions       []preconditionFailureDTO `json:"preconditions"`
+	FailedPreconditions []preconditionFailureDTO `json:"failedPreconditions"`
+}
+
+type preconditionFailureDTO struct {
+	Index   *int                   `json:"index"`
+	Type    string                 `json:"type"`
+	Payload map[string]interface{} `json:"payload"`
+	Reason  string                 `json:"reason"`
+	Message string                 `json:"message"`
+}
+
+// parsePreconditionFailures extracts the failed preconditions from the body
+// of a 412 response. Entries are matched against the submitted preconditions
+// by index or by type and payload. If the server gives no details and only
+// one precondition was submitted, that one is reported.
+func parsePreconditionFailures(body string, submitted []Precondition) []PreconditionFailure {
+	var parsed preconditionFailureBody
+	if err := json.Unmarshal([]byte(body), &parsed); err != nil {
+		if len(submitted) == 1 {
+			return []PreconditionFailure{{Precondition: submitted[0], Reason: body}}
+		}
+		return nil
+	}
+
+	entries := parsed.FailedPreconditions
+	if len(entries) == 0 {
+		entries = parsed.Preconditions
+	}
+
+	reason := parsed.Message
+	if reason == "" {
+		reason = parsed.Error
+	}
+
+	if len(entries) == 0 {
+		if len(submitted) == 1 {
+			return []PreconditionFailure{{Precondition: submitted[0], Reason: reason}}
+		}
+		return nil
+	}
+
+	failures := make([]PreconditionFailure, 0, len(entries))
+	for _, entry := range entries {
+		failure := PreconditionFailure{
+			Precondition: Precondition{Type: entry.Type, Payload: entry.Payload},
+			Reason:       entry.Reason,
+		}
+		if failure.Reason == "" {
+			failure.Reason = entry.Message
+		}
+		if failure.Reason == "" {
+			failure.Reason = reason
+		}
+
+		if entry.Index != nil && *entry.Index >= 0 && *entry.Index < len(submitted) {
+			failure.Precondition = submitted[*entry.Index]
+		} else {
+			for _, p := range submitted {
+				if p.Type == entry.Type && (entry.Payload == nil || reflect.DeepEqual(p.Payload, entry.Payload)) {
+					failure.Precondition = p
+					break
+				}
+			}
+		}
+
+		failures = append(failures, failure)
+	}
+
+	return failures
+}
@@ -0,0 +1,109 @@
+package genesisdb
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPIError_Is(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		target     error
+		want       bool
+	}{
+		{412, ErrPreconditionFailed, true},
+		{401, ErrUnauthorized, true},
+		{403, ErrUnauthorized, true},
+		{404, ErrNotFound, true},
+		{503, ErrServerUnavailable, true},
+		{504, ErrServerUnavailable, true},
+		{500, ErrServerUnavailable, false},
+		{400, ErrPreconditionFailed, false},
+	}
+
+	for _, tt := range tests {
+		err := error(&APIError{StatusCode: tt.statusCode})
+		if got := errors.Is(err, tt.target); got != tt.want {
+			t.Errorf("errors.Is(%d, %v) = %v, want %v", tt.statusCode, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestAPIError_Mock(t *testing.T) {
+	config := &Config{
+		APIURL:     "http://localhost:8080",
+		APIVersion: "v1",
+		AuthToken:  "test-token",
+	}
+
+	respond := func(statusCode int, body string) *mockRoundTripper {
+		return &mockRoundTripper{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: statusCode,
+					Status:     http.StatusText(statusCode),
+					Body:       io.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}
+	}
+
+	t.Run("Status code and endpoint", func(t *testing.T) {
+		client, _ := NewClient(config, WithTransport(respond(401, "invalid token")))
+
+		_, err := client.StreamEvents("/test", nil)
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("StreamEvents() error = %v, want *APIError", err)
+		}
+		if apiErr.StatusCode != 401 || apiErr.Body != "invalid token" || apiErr.Endpoint != "/api/v1/stream" {
+			t.Errorf("Unexpected APIError: %+v", apiErr)
+		}
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Error("Error should match ErrUnauthorized")
+		}
+	})
+
+	t.Run("Failed preconditions from response", func(t *testing.T) {
+		body := `{"message":"precondition failed","failedPreconditions":[{"type":"isSubjectNew","payload":{"subject":"/b"},"reason":"subject exists"}]}`
+		client, _ := NewClient(config, WithTransport(respond(412, body)))
+
+		preconditions := []Precondition{
+			{Type: "isSubjectNew", Payload: map[string]interface{}{"subject": "/a"}},
+			{Type: "isSubjectNew", Payload: map[string]interface{}{"subject": "/b"}},
+		}
+		err := client.CommitEventsWithPreconditions([]Event{{Subject: "/b", Type: "test.event"}}, preconditions)
+
+		if !errors.Is(err, ErrPreconditionFailed) {
+			t.Fatalf("CommitEventsWithPreconditions() error = %v, want ErrPreconditionFailed", err)
+		}
+		var apiErr *APIError
+		errors.As(err, &apiErr)
+		if len(apiErr.FailedPreconditions) != 1 {
+			t.Fatalf("Expected 1 failed precondition, got %d", len(apiErr.FailedPreconditions))
+		}
+		failure := apiErr.FailedPreconditions[0]
+		if failure.Precondition.Payload["subject"] != "/b" || failure.Reason != "subject exists" {
+			t.Errorf("Unexpected failure: %+v", failure)
+		}
+	})
+
+	t.Run("Single precondition without details", func(t *testing.T) {
+		client, _ := NewClient(config, WithTransport(respond(412, "Precondition Failed")))
+
+		preconditions := []Precondition{{Type: "isSubjectExisting", Payload: map[string]interface{}{"subject": "/a"}}}
+		err := client.CommitEventsWithPreconditions([]Event{{Subject: "/a", Type: "test.event"}}, preconditions)
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || len(apiErr.FailedPreconditions) != 1 {
+			t.Fatalf("Expected one failed precondition, got %v", err)
+		}
+		if apiErr.FailedPreconditions[0].Precondition.Type != "isSubjectExisting" {
+			t.Errorf("Unexpected failure: %+v", apiErr.FailedPreconditions[0])
+		}
+	})
+}
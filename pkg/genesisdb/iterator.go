@@ -0,0 +1,255 @@
+package genesisdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// lineIterator reads the non-empty lines of an NDJSON body one at a time and
+// releases the body once the last line has been read or an error occurred.
+type lineIterator struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	line    string
+	number  int
+	err     error
+	closed  bool
+}
+
+func newLineIterator(ctx context.Context, cancel context.CancelFunc, body io.ReadCloser) *lineIterator {
+	return &lineIterator{
+		ctx:     ctx,
+		cancel:  cancel,
+		body:    body,
+		scanner: bufio.NewScanner(body),
+	}
+}
+
+func (it *lineIterator) next() bool {
+	if it.closed {
+		return false
+	}
+
+	for it.scanner.Scan() {
+		it.number++
+		if err := it.ctx.Err(); err != nil {
+			it.fail(err)
+			return false
+		}
+
+		line := strings.TrimSpace(it.scanner.Text())
+		if line == "" {
+			continue
+		}
+		it.line = line
+		return true
+	}
+
+	if err := it.scanner.Err(); err != nil {
+		if ctxErr := it.ctx.Err(); ctxErr != nil {
+			it.fail(ctxErr)
+		} else {
+			it.fail(fmt.Errorf("error reading response: %w", err))
+		}
+		return false
+	}
+
+	it.fail(it.ctx.Err())
+	return false
+}
+
+func (it *lineIterator) fail(err error) {
+	if it.err == nil {
+		it.err = err
+	}
+	it.close()
+}
+
+func (it *lineIterator) close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	err := it.body.Close()
+	it.cancel()
+	return err
+}
+
+// EventIterator decodes the events of a stream lazily as the response body
+// is read. It must be closed if it is not read to the end.
+//
+//	it, err := client.StreamEventsIterator(ctx, "/customer", nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		handle(it.Event())
+//	}
+//	return it.Err()
+type EventIterator struct {
+	es      *Genesisdb
+	lines   *lineIterator
+	event   Event
+	pending []Event
+	// source is the ID of the received event the current one derives from
+	source string
+
+	// raw skips the registry, for internal lookups by event ID
+	raw bool
+	// invalid is set if the iteration stopped at an event that could not be
+	// decoded or prepared, which reading the stream again would not change
+	invalid bool
+}
+
+// Next advances to the next event and reports whether there is one. It
+// returns false at the end of the stream or on error; check Err afterwards.
+func (it *EventIterator) Next() bool {
+	if len(it.pending) > 0 {
+		it.event, it.pending = it.pending[0], it.pending[1:]
+		return true
+	}
+
+	for it.lines.next() {
+		var event Event
+		if err := json.Unmarshal([]byte(it.lines.line), &event); err != nil {
+			it.invalid = true
+			it.lines.fail(fmt.Errorf("error parsing event JSON: %w", err))
+			return false
+		}
+		it.source = event.ID
+
+		if it.raw {
+			it.es.populateDefaults(&event)
+			event.resumeID = event.ID
+			it.event = event
+			return true
+		}
+
+		events, err := it.es.prepareEvents(event)
+		if err != nil {
+			it.invalid = true
+			it.lines.fail(err)
+			return false
+		}
+		if len(events) > 0 {
+			it.event, it.pending = events[0], events[1:]
+			return true
+		}
+	}
+	return false
+}
+
+// Event returns the event Next advanced to.
+func (it *EventIterator) Event() Event {
+	return it.event
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *EventIterator) Err() error {
+	return it.lines.err
+}
+
+// Close releases the connection. It is safe to call Close more than once.
+func (it *EventIterator) Close() error {
+	return it.lines.close()
+}
+
+// ResultIterator decodes query results lazily as the response body is read.
+// It is used like EventIterator.
+type ResultIterator struct {
+	lines  *lineIterator
+	result interface{}
+}
+
+// Next advances to the next result and reports whether there is one. It
+// returns false at the end of the results or on error; check Err afterwards.
+func (it *ResultIterator) Next() bool {
+	if !it.lines.next() {
+		return false
+	}
+
+	var result interface{}
+	if err := json.Unmarshal([]byte(it.lines.line), &result); err != nil {
+		it.lines.fail(fmt.Errorf("error parsing result JSON: %w", err))
+		return false
+	}
+
+	it.result = result
+	return true
+}
+
+// Result returns the result Next advanced to.
+func (it *ResultIterator) Result() interface{} {
+	return it.result
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ResultIterator) Err() error {
+	return it.lines.err
+}
+
+// Close releases the connection. It is safe to call Close more than once.
+func (it *ResultIterator) Close() error {
+	return it.lines.close()
+}
+
+// StreamEventsIterator is like StreamEventsContext but returns an iterator
+// that decodes events as they are read instead of collecting them all in
+// memory.
+func (es *Genesisdb) StreamEventsIterator(ctx context.Context, subject string, options *StreamOptions) (*EventIterator, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	lines, err := es.openLines(ctx, OperationStream, StreamRequest{
+		Subject: subject,
+		Options: options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &EventIterator{es: es, lines: lines}, nil
+}
+
+// QIterator is like QContext but returns an iterator that decodes results as
+// they are read instead of collecting them all in memory.
+func (es *Genesisdb) QIterator(ctx context.Context, query string, params ...Params) (*ResultIterator, error) {
+	query, err := bindQuery(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := es.openLines(ctx, OperationQuery, map[string]string{"query": query})
+	if err != nil {
+		return nil, err
+	}
+	return &ResultIterator{lines: lines}, nil
+}
+
+// openLines posts body to the NDJSON endpoint of op and returns an iterator
+// over the lines of the response.
+func (es *Genesisdb) openLines(ctx context.Context, op Operation, body interface{}) (*lineIterator, error) {
+	ctx, cancel := es.withTimeout(ctx, op)
+
+	req, err := es.newRequest(ctx, "POST", string(op), body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	resp, err := es.do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return newLineIterator(ctx, cancel, resp.Body), nil
+}
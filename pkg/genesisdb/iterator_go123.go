@@ -0,0 +1,85 @@
+//go:build go1.23
+
+package genesisdb
+
+import (
+	"context"
+	"iter"
+)
+
+// StreamEventsSeq returns the events of a stream as a sequence for use with
+// range. Events are decoded as they are read; an error ends the sequence and
+// is yielded with a zero Event. Breaking out of the loop closes the
+// connection.
+//
+//	for event, err := range client.StreamEventsSeq(ctx, "/customer", nil) {
+//		if err != nil {
+//			return err
+//		}
+//		handle(event)
+//	}
+func (es *Genesisdb) StreamEventsSeq(ctx context.Context, subject string, options *StreamOptions) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		it, err := es.StreamEventsIterator(ctx, subject, options)
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		defer it.Close()
+
+		for it.Next() {
+			if !yield(it.Event(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(Event{}, err)
+		}
+	}
+}
+
+// QSeq returns the results of a query as a sequence for use with range, like
+// StreamEventsSeq.
+func (es *Genesisdb) QSeq(ctx context.Context, query string, params ...Params) iter.Seq2[interface{}, error] {
+	return func(yield func(interface{}, error) bool) {
+		it, err := es.QIterator(ctx, query, params...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer it.Close()
+
+		for it.Next() {
+			if !yield(it.Result(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// QueryAsSeq returns the results of a query decoded into T as a sequence
+// for use with range, like StreamEventsSeq.
+func QueryAsSeq[T any](ctx context.Context, es *Genesisdb, query string, opts ...QueryOption) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		it, err := QueryAsIterator[T](ctx, es, query, opts...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer it.Close()
+
+		for it.Next() {
+			if !yield(it.Result(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
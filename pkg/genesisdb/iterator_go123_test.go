@@ -0,0 +1,59 @@
+//go:build go1.23
+
+package genesisdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamEventsSeq_Mock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		for _, id := range []string{"1", "2", "3"} {
+			eventJSON, _ := json.Marshal(Event{ID: id, Subject: "/test", Type: "test.event"})
+			w.Write([]byte(string(eventJSON) + "\n"))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"})
+
+	var ids []string
+	for event, err := range client.StreamEventsSeq(context.Background(), "/test", nil) {
+		if err != nil {
+			t.Fatalf("StreamEventsSeq() error = %v", err)
+		}
+		ids = append(ids, event.ID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	if len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+		t.Errorf("Received events %v, want [1 2]", ids)
+	}
+}
+
+func TestQueryAsSeq_Mock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte(`{"id":"1","firstName":"Bruce"}` + "\n" + `{"id":"2","firstName":"Alfred"}` + "\n"))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"})
+
+	var ids []string
+	for result, err := range QueryAsSeq[customerProjection](context.Background(), client, "STREAM e FROM events") {
+		if err != nil {
+			t.Fatalf("QueryAsSeq() error = %v", err)
+		}
+		ids = append(ids, result.ID)
+	}
+	if len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+		t.Errorf("Received results %v, want [1 2]", ids)
+	}
+}
@@ -0,0 +1,128 @@
+package genesisdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIterator_Mock(t *testing.T) {
+	config := &Config{
+		APIVersion: "v1",
+		AuthToken:  "test-token",
+	}
+
+	t.Run("Events are decoded lazily", func(t *testing.T) {
+		proceed := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			for i, id := range []string{"1", "2"} {
+				if i == 1 {
+					<-proceed
+				}
+				eventJSON, _ := json.Marshal(Event{ID: id, Subject: "/test", Type: "test.event"})
+				w.Write([]byte(string(eventJSON) + "\n\n"))
+				w.(http.Flusher).Flush()
+			}
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		it, err := client.StreamEventsIterator(context.Background(), "/test", nil)
+		if err != nil {
+			t.Fatalf("StreamEventsIterator() error = %v", err)
+		}
+		defer it.Close()
+
+		// The first event must be available before the server sends the second
+		if !it.Next() || it.Event().ID != "1" {
+			t.Fatalf("Expected event 1, got %+v (err %v)", it.Event(), it.Err())
+		}
+		close(proceed)
+		if !it.Next() || it.Event().ID != "2" {
+			t.Fatalf("Expected event 2, got %+v (err %v)", it.Event(), it.Err())
+		}
+		if it.Next() {
+			t.Error("Expected end of stream")
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("Err() = %v", err)
+		}
+	})
+
+	t.Run("Invalid JSON stops iteration", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Write([]byte(`{"result": 1}` + "\n" + "invalid json\n" + `{"result": 2}` + "\n"))
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		it, err := client.QIterator(context.Background(), "FROM e IN events")
+		if err != nil {
+			t.Fatalf("QIterator() error = %v", err)
+		}
+		defer it.Close()
+
+		count := 0
+		for it.Next() {
+			count++
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 result before the error, got %d", count)
+		}
+		if it.Err() == nil || !strings.Contains(it.Err().Error(), "error parsing result JSON") {
+			t.Errorf("Err() = %v, want parse error", it.Err())
+		}
+	})
+
+	t.Run("API error is returned on open", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(500)
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		if _, err := client.StreamEventsIterator(context.Background(), "/test", nil); err == nil {
+			t.Error("StreamEventsIterator() should return error")
+		}
+	})
+}
+
+func TestEventExists_Mock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req StreamRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Options == nil || !req.Options.IncludeLowerBoundEvent {
+			t.Errorf("Unexpected options: %+v", req.Options)
+		}
+		w.WriteHeader(200)
+		if req.Options.LowerBound == "1" {
+			eventJSON, _ := json.Marshal(Event{ID: "1", Subject: "/test", Type: "unknown.event"})
+			w.Write([]byte(string(eventJSON) + "\n"))
+		}
+	}))
+	defer server.Close()
+
+	// The registry would skip the event, the lookup must not
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"}, WithRegistry(NewRegistry(UnknownTypeSkip)))
+
+	if ok, err := client.EventExists(context.Background(), "/test", "1"); err != nil || !ok {
+		t.Errorf("EventExists(1) = %v, %v, want true", ok, err)
+	}
+	if ok, err := client.EventExists(context.Background(), "/test", "2"); err != nil || ok {
+		t.Errorf("EventExists(2) = %v, %v, want false", ok, err)
+	}
+}
@@ -0,0 +1,92 @@
+package genesisdb
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Operation identifies an API call for the purpose of per-operation
+// settings such as timeouts.
+type Operation string
+
+const (
+	OperationStream  Operation = "stream"
+	OperationCommit  Operation = "commit"
+	OperationErase   Operation = "erase"
+	OperationQuery   Operation = "q"
+	OperationPing    Operation = "status/ping"
+	OperationAudit   Operation = "status/audit"
+	OperationObserve Operation = "observe"
+)
+
+const defaultUserAgent = "genesisdb-sdk"
+
+// Option customizes a client created by NewClient.
+type Option func(*Genesisdb)
+
+// WithHTTPClient makes the client send its requests through httpClient
+// instead of a default http.Client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(es *Genesisdb) {
+		if httpClient != nil {
+			es.client = httpClient
+		}
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests, e.g. a proxy or
+// mTLS transport. It is applied after WithHTTPClient.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(es *Genesisdb) {
+		es.transport = transport
+	}
+}
+
+// WithTimeout bounds every operation except observe to d. Streaming calls
+// must finish reading their response within d as well.
+func WithTimeout(d time.Duration) Option {
+	return func(es *Genesisdb) {
+		es.defaultTimeout = d
+	}
+}
+
+// WithOperationTimeout bounds a single operation to d, overriding
+// WithTimeout. For OperationObserve the timeout applies to each connection.
+func WithOperationTimeout(op Operation, d time.Duration) Option {
+	return func(es *Genesisdb) {
+		if es.timeouts == nil {
+			es.timeouts = make(map[Operation]time.Duration)
+		}
+		es.timeouts[op] = d
+	}
+}
+
+// WithUserAgent appends suffix to the User-Agent header sent with every
+// request.
+func WithUserAgent(suffix string) Option {
+	return func(es *Genesisdb) {
+		es.userAgent = defaultUserAgent + " " + suffix
+	}
+}
+
+// WithHeader adds a header that is sent with every request. Headers set by
+// the SDK itself, such as Authorization, take precedence.
+func WithHeader(key, value string) Option {
+	return func(es *Genesisdb) {
+		es.headers.Add(key, value)
+	}
+}
+
+// withTimeout derives a context bounded by the timeout configured for op.
+// Observe is only bounded if it was configured explicitly.
+func (es *Genesisdb) withTimeout(ctx context.Context, op Operation) (context.Context, context.CancelFunc) {
+	d, ok := es.timeouts[op]
+	if !ok && op != OperationObserve {
+		d = es.defaultTimeout
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,112 @@
+package genesisdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptions_Mock(t *testing.T) {
+	config := &Config{
+		APIURL:     "http://localhost:8080",
+		APIVersion: "v1",
+		AuthToken:  "test-token",
+	}
+
+	t.Run("Custom transport", func(t *testing.T) {
+		called := false
+		mockTransport := &mockRoundTripper{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				called = true
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("pong"))}, nil
+			},
+		}
+
+		client, err := NewClient(config, WithTransport(mockTransport))
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+
+		if _, err := client.Ping(); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+		if !called {
+			t.Error("Custom transport was not used")
+		}
+	})
+
+	t.Run("Custom HTTP client is not modified", func(t *testing.T) {
+		httpClient := &http.Client{}
+		mockTransport := &mockRoundTripper{}
+
+		client, _ := NewClient(config, WithHTTPClient(httpClient), WithTransport(mockTransport))
+
+		if httpClient.Transport != nil {
+			t.Error("WithTransport should not modify the injected http.Client")
+		}
+		if client.client.Transport != mockTransport {
+			t.Error("Client should use the custom transport")
+		}
+	})
+
+	t.Run("User-Agent and default headers", func(t *testing.T) {
+		mockTransport := &mockRoundTripper{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				if ua := req.Header.Get("User-Agent"); ua != "genesisdb-sdk billing/1.2" {
+					t.Errorf("Unexpected User-Agent: %s", ua)
+				}
+				if v := req.Header.Get("X-Tenant"); v != "acme" {
+					t.Errorf("Unexpected X-Tenant header: %s", v)
+				}
+				if auth := req.Header.Get("Authorization"); auth != "Bearer test-token" {
+					t.Errorf("Authorization header should not be overridden, got: %s", auth)
+				}
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("pong"))}, nil
+			},
+		}
+
+		client, _ := NewClient(config,
+			WithTransport(mockTransport),
+			WithUserAgent("billing/1.2"),
+			WithHeader("X-Tenant", "acme"),
+			WithHeader("Authorization", "Bearer other"),
+		)
+
+		if _, err := client.Ping(); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+	})
+
+	t.Run("Operation timeouts", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v1/status/ping" {
+				select {
+				case <-time.After(time.Second):
+				case <-r.Context().Done():
+				}
+			}
+			w.WriteHeader(200)
+			w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg,
+			WithTimeout(50*time.Millisecond),
+			WithOperationTimeout(OperationAudit, 0),
+		)
+
+		if _, err := client.Ping(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Ping() error = %v, want context.DeadlineExceeded", err)
+		}
+		if _, err := client.Audit(); err != nil {
+			t.Errorf("Audit() error = %v", err)
+		}
+	})
+}
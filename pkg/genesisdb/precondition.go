@@ -0,0 +1,134 @@
+package genesisdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Precondition types supported by the API.
+const (
+	PreconditionIsSubjectNew      = "isSubjectNew"
+	PreconditionIsSubjectExisting = "isSubjectExisting"
+	PreconditionIsQueryResultTrue = "isQueryResultTrue"
+)
+
+// ErrInvalidPrecondition is returned before sending a commit whose
+// preconditions have an unknown type or lack their payload.
+var ErrInvalidPrecondition = errors.New("invalid precondition")
+
+// preconditionPayloadKeys maps every known precondition type to the payload
+// key it requires.
+var preconditionPayloadKeys = map[string]string{
+	PreconditionIsSubjectNew:      "subject",
+	PreconditionIsSubjectExisting: "subject",
+	PreconditionIsQueryResultTrue: "query",
+}
+
+// IsSubjectNew holds if no events exist for subject.
+func IsSubjectNew(subject string) Precondition {
+	return Precondition{Type: PreconditionIsSubjectNew, Payload: map[string]interface{}{"subject": subject}}
+}
+
+// IsSubjectExisting holds if events exist for subject.
+func IsSubjectExisting(subject string) Precondition {
+	return Precondition{Type: PreconditionIsSubjectExisting, Payload: map[string]interface{}{"subject": subject}}
+}
+
+// IsQueryResultTrue holds if the GDBQL query evaluates to a truthy result.
+func IsQueryResultTrue(query string) Precondition {
+	return Precondition{Type: PreconditionIsQueryResultTrue, Payload: map[string]interface{}{"query": query}}
+}
+
+// Validate checks that p has a known type and the payload that type needs.
+func (p Precondition) Validate() error {
+	key, ok := preconditionPayloadKeys[p.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPrecondition, p.Type)
+	}
+	if value, _ := p.Payload[key].(string); value == "" {
+		return fmt.Errorf("%w: %s requires a non-empty %q", ErrInvalidPrecondition, p.Type, key)
+	}
+	return nil
+}
+
+// validatePreconditions validates every precondition of a commit.
+func validatePreconditions(preconditions []Precondition) error {
+	for i, p := range preconditions {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("precondition %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SubjectsNew holds if none of the subjects has events.
+func SubjectsNew(subjects ...string) []Precondition {
+	preconditions := make([]Precondition, len(subjects))
+	for i, subject := range subjects {
+		preconditions[i] = IsSubjectNew(subject)
+	}
+	return preconditions
+}
+
+// SubjectsExisting holds if every subject has events.
+func SubjectsExisting(subjects ...string) []Precondition {
+	preconditions := make([]Precondition, len(subjects))
+	for i, subject := range subjects {
+		preconditions[i] = IsSubjectExisting(subject)
+	}
+	return preconditions
+}
+
+// AllOf combines groups of preconditions into one list, all of which must
+// hold for the commit to succeed.
+func AllOf(groups ...[]Precondition) []Precondition {
+	var preconditions []Precondition
+	for _, group := range groups {
+		preconditions = append(preconditions, group...)
+	}
+	return preconditions
+}
+
+// PreconditionBuilder collects preconditions fluently:
+//
+//	preconditions, err := genesisdb.NewPreconditionBuilder().
+//		SubjectNew("/user/456").
+//		QueryResultTrue("STREAM e FROM events WHERE e.data.email == 'john.doe@example.com' MAP COUNT() == 0").
+//		Build()
+type PreconditionBuilder struct {
+	preconditions []Precondition
+}
+
+// NewPreconditionBuilder creates an empty builder.
+func NewPreconditionBuilder() *PreconditionBuilder {
+	return &PreconditionBuilder{}
+}
+
+// SubjectNew adds IsSubjectNew(subject).
+func (b *PreconditionBuilder) SubjectNew(subject string) *PreconditionBuilder {
+	return b.Add(IsSubjectNew(subject))
+}
+
+// SubjectExisting adds IsSubjectExisting(subject).
+func (b *PreconditionBuilder) SubjectExisting(subject string) *PreconditionBuilder {
+	return b.Add(IsSubjectExisting(subject))
+}
+
+// QueryResultTrue adds IsQueryResultTrue(query).
+func (b *PreconditionBuilder) QueryResultTrue(query string) *PreconditionBuilder {
+	return b.Add(IsQueryResultTrue(query))
+}
+
+// Add adds arbitrary preconditions.
+func (b *PreconditionBuilder) Add(preconditions ...Precondition) *PreconditionBuilder {
+	b.preconditions = append(b.preconditions, preconditions...)
+	return b
+}
+
+// Build returns the collected preconditions after validating them.
+func (b *PreconditionBuilder) Build() ([]Precondition, error) {
+	if err := validatePreconditions(b.preconditions); err != nil {
+		return nil, err
+	}
+	return append([]Precondition(nil), b.preconditions...), nil
+}
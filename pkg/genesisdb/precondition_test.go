@@ -0,0 +1,89 @@
+package genesisdb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPrecondition_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		precondition Precondition
+		wantErr      bool
+	}{
+		{"Subject new", IsSubjectNew("/user/456"), false},
+		{"Subject existing", IsSubjectExisting("/user/456"), false},
+		{"Query result true", IsQueryResultTrue("STREAM e FROM events MAP COUNT() == 0"), false},
+		{"Unknown type", Precondition{Type: "isSubjectNw", Payload: map[string]interface{}{"subject": "/a"}}, true},
+		{"Wrong payload key", Precondition{Type: "isSubjectNew", Payload: map[string]interface{}{"subjcet": "/a"}}, true},
+		{"Empty subject", IsSubjectExisting(""), true},
+		{"Missing payload", Precondition{Type: "isQueryResultTrue"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.precondition.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPrecondition) {
+				t.Errorf("Validate() error = %v, want ErrInvalidPrecondition", err)
+			}
+		})
+	}
+}
+
+func TestPreconditionBuilder(t *testing.T) {
+	preconditions, err := NewPreconditionBuilder().
+		SubjectNew("/user/456").
+		SubjectExisting("/company/1").
+		QueryResultTrue("STREAM e FROM events MAP COUNT() == 0").
+		Add(SubjectsNew("/a", "/b")...).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	want := AllOf(
+		[]Precondition{IsSubjectNew("/user/456"), IsSubjectExisting("/company/1")},
+		[]Precondition{IsQueryResultTrue("STREAM e FROM events MAP COUNT() == 0")},
+		SubjectsNew("/a", "/b"),
+	)
+	if !reflect.DeepEqual(preconditions, want) {
+		t.Errorf("Build() = %v, want %v", preconditions, want)
+	}
+
+	if _, err := NewPreconditionBuilder().SubjectNew("").Build(); !errors.Is(err, ErrInvalidPrecondition) {
+		t.Errorf("Build() error = %v, want ErrInvalidPrecondition", err)
+	}
+}
+
+func TestPrecondition_Mock(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"})
+	events := []Event{{Source: "io.genesisdb.app", Subject: "/user/456", Type: "user-created"}}
+
+	err := client.CommitEventsWithPreconditions(events, []Precondition{{Type: "isSubjectFresh", Payload: map[string]interface{}{"subject": "/user/456"}}})
+	if !errors.Is(err, ErrInvalidPrecondition) {
+		t.Fatalf("CommitEventsWithPreconditions() error = %v, want ErrInvalidPrecondition", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request for invalid preconditions, got %d", requests)
+	}
+
+	if err := client.CommitEventsWithPreconditions(events, SubjectsNew("/user/456")); err != nil {
+		t.Fatalf("CommitEventsWithPreconditions() error = %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 request, got %d", requests)
+	}
+}
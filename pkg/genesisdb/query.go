@@ -0,0 +1,349 @@
+package genesisdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQueryParam is returned when a query cannot be bound to its
+// parameters: a placeholder has no value or a value has no GDBQL literal.
+var ErrInvalidQueryParam = errors.New("invalid query parameter")
+
+// Params holds the values for the $name placeholders of a GDBQL query.
+type Params map[string]interface{}
+
+// BindParams replaces every $name placeholder in query with the GDBQL
+// literal of params[name]. Strings are quoted and escaped, so user input
+// cannot change the structure of the query. Placeholders inside string
+// literals are left alone, and $$ stands for a literal $.
+//
+// Supported values are strings, booleans, numbers, nil, json.Number,
+// time.Time (as an RFC 3339 string) and slices or arrays of those, which
+// become lists for use with IN.
+func BindParams(query string, params Params) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end, err := skipStringLiteral(query, i)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(query[i:end])
+			i = end
+		case c == '$' && i+1 < len(query) && query[i+1] == '$':
+			b.WriteByte('$')
+			i += 2
+		case c == '$' && i+1 < len(query) && isIdentStart(query[i+1]):
+			end := i + 2
+			for end < len(query) && isIdentPart(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+			value, ok := params[name]
+			if !ok {
+				return "", fmt.Errorf("%w: no value for $%s", ErrInvalidQueryParam, name)
+			}
+			literal, err := formatLiteral(value)
+			if err != nil {
+				return "", fmt.Errorf("%w: $%s: %v", ErrInvalidQueryParam, name, err)
+			}
+			b.WriteString(literal)
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// bindQuery binds the merged params to query, or returns query unchanged if
+// there are none.
+func bindQuery(query string, params []Params) (string, error) {
+	if len(params) == 0 {
+		return query, nil
+	}
+	merged := make(Params)
+	for _, p := range params {
+		for name, value := range p {
+			merged[name] = value
+		}
+	}
+	return BindParams(query, merged)
+}
+
+// skipStringLiteral returns the index after the string literal starting at
+// query[start].
+func skipStringLiteral(query string, start int) (int, error) {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated string literal at offset %d", ErrInvalidQueryParam, start)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// QuoteString returns s as a single-quoted GDBQL string literal.
+func QuoteString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// formatLiteral returns the GDBQL literal for value.
+func formatLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case time.Time:
+		return QuoteString(v.Format(time.RFC3339Nano)), nil
+	case json.Number:
+		if _, err := strconv.ParseFloat(string(v), 64); err != nil {
+			return "", fmt.Errorf("invalid number %q", v)
+		}
+		return string(v), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return QuoteString(rv.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("%v has no literal", f)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			item, err := formatLiteral(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return "null", nil
+		}
+		return formatLiteral(rv.Elem().Interface())
+	}
+	return "", fmt.Errorf("unsupported type %T", value)
+}
+
+// QueryBuilder builds a GDBQL query clause by clause. Conditions are written
+// in GDBQL and refer to values through $name placeholders, which Build binds
+// to the parameters given with Bind:
+//
+//	query, err := genesisdb.NewQuery("e").
+//		Where("e.type == $type").
+//		Under("/user/123").
+//		OrderBy("e.time DESC").
+//		Limit(20).
+//		Map("{ id: e.id, email: e.data.email }").
+//		Bind(genesisdb.Params{"type": "io.genesisdb.app.user-created"}).
+//		Build()
+type QueryBuilder struct {
+	variable string
+	source   string
+	where    []string
+	groupBy  []string
+	having   []string
+	orderBy  []string
+	limit    int
+	project  string
+	params   Params
+	err      error
+}
+
+// NewQuery starts a query that streams events bound to variable, e.g. "e".
+func NewQuery(variable string) *QueryBuilder {
+	return &QueryBuilder{variable: variable, source: "events", params: make(Params)}
+}
+
+// From changes the source of the query, which defaults to "events".
+func (q *QueryBuilder) From(source string) *QueryBuilder {
+	q.source = source
+	return q
+}
+
+// Where adds a condition. Several conditions must all hold.
+func (q *QueryBuilder) Where(condition string) *QueryBuilder {
+	q.where = append(q.where, condition)
+	return q
+}
+
+// WhereEquals adds the condition field == value with value as a bound
+// literal.
+func (q *QueryBuilder) WhereEquals(field string, value interface{}) *QueryBuilder {
+	return q.whereLiteral(field+" == ", value)
+}
+
+// WhereIn adds the condition field IN [values...] with the values as bound
+// literals.
+func (q *QueryBuilder) WhereIn(field string, values ...interface{}) *QueryBuilder {
+	return q.whereLiteral(field+" IN ", values)
+}
+
+// Under restricts the query to events whose subject is subject or lies
+// below it.
+func (q *QueryBuilder) Under(subject string) *QueryBuilder {
+	return q.whereLiteral(q.variable+".subject UNDER ", subject)
+}
+
+func (q *QueryBuilder) whereLiteral(prefix string, value interface{}) *QueryBuilder {
+	literal, err := formatLiteral(value)
+	if err != nil {
+		if q.err == nil {
+			q.err = fmt.Errorf("%w: %s: %v", ErrInvalidQueryParam, strings.TrimSpace(prefix), err)
+		}
+		return q
+	}
+	// Build leaves string literals alone, so the literal needs no escaping
+	return q.Where(prefix + literal)
+}
+
+// GroupBy groups the results by the given expressions.
+func (q *QueryBuilder) GroupBy(expressions ...string) *QueryBuilder {
+	q.groupBy = append(q.groupBy, expressions...)
+	return q
+}
+
+// Having adds a condition on groups. Several conditions must all hold.
+func (q *QueryBuilder) Having(condition string) *QueryBuilder {
+	q.having = append(q.having, condition)
+	return q
+}
+
+// OrderBy sorts the results, e.g. OrderBy("e.time DESC").
+func (q *QueryBuilder) OrderBy(expressions ...string) *QueryBuilder {
+	q.orderBy = append(q.orderBy, expressions...)
+	return q
+}
+
+// Limit returns at most n results.
+func (q *QueryBuilder) Limit(n int) *QueryBuilder {
+	q.limit = n
+	return q
+}
+
+// Map sets the expression every result is mapped to, e.g. "COUNT() == 0"
+// or "{ id: e.id }".
+func (q *QueryBuilder) Map(expression string) *QueryBuilder {
+	q.project = expression
+	return q
+}
+
+// Bind adds values for the $name placeholders of the query.
+func (q *QueryBuilder) Bind(params Params) *QueryBuilder {
+	for name, value := range params {
+		q.params[name] = value
+	}
+	return q
+}
+
+// Build returns the query with all placeholders bound.
+func (q *QueryBuilder) Build() (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	if q.variable == "" {
+		return "", errors.New("query requires a stream variable")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "STREAM %s FROM %s", q.variable, q.source)
+	if len(q.where) > 0 {
+		b.WriteString(" WHERE " + joinConditions(q.where))
+	}
+	if len(q.groupBy) > 0 {
+		b.WriteString(" GROUP BY " + strings.Join(q.groupBy, ", "))
+	}
+	if len(q.having) > 0 {
+		b.WriteString(" HAVING " + joinConditions(q.having))
+	}
+	if len(q.orderBy) > 0 {
+		b.WriteString(" ORDER BY " + strings.Join(q.orderBy, ", "))
+	}
+	if q.limit > 0 {
+		fmt.Fprintf(&b, " LIMIT %d", q.limit)
+	}
+	if q.project != "" {
+		b.WriteString(" MAP " + q.project)
+	}
+
+	return BindParams(b.String(), q.params)
+}
+
+// joinConditions joins conditions with AND, parenthesizing each one so that
+// an OR inside a condition keeps its meaning.
+func joinConditions(conditions []string) string {
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	parts := make([]string, len(conditions))
+	for i, condition := range conditions {
+		parts[i] = "(" + condition + ")"
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// IsQueryResultTrueWithParams is like IsQueryResultTrue but binds params to
+// the $name placeholders of query first, see BindParams.
+func IsQueryResultTrueWithParams(query string, params Params) (Precondition, error) {
+	bound, err := BindParams(query, params)
+	if err != nil {
+		return Precondition{}, err
+	}
+	return IsQueryResultTrue(bound), nil
+}
@@ -0,0 +1,203 @@
+package genesisdb
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBindParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		params  Params
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "String",
+			query:  "STREAM e FROM events WHERE e.data.email == $email MAP COUNT() == 0",
+			params: Params{"email": "john.doe@example.com"},
+			want:   "STREAM e FROM events WHERE e.data.email == 'john.doe@example.com' MAP COUNT() == 0",
+		},
+		{
+			name:   "Injection",
+			query:  "STREAM e FROM events WHERE e.data.email == $email",
+			params: Params{"email": `x' OR '1' == '1`},
+			want:   `STREAM e FROM events WHERE e.data.email == 'x\' OR \'1\' == \'1'`,
+		},
+		{
+			name:   "Backslash and newline",
+			query:  "WHERE e.data.path == $path",
+			params: Params{"path": "C:\\tmp\n"},
+			want:   `WHERE e.data.path == 'C:\\tmp\n'`,
+		},
+		{
+			name:  "Scalars and lists",
+			query: "WHERE e.data.amount + $amount <= $limit AND e.data.active == $active AND e.data.note == $note AND e.data.tier IN $tiers",
+			params: Params{
+				"amount": 500.5,
+				"limit":  int64(10000),
+				"active": true,
+				"note":   nil,
+				"tiers":  []string{"gold", "premium"},
+			},
+			want: "WHERE e.data.amount + 500.5 <= 10000 AND e.data.active == true AND e.data.note == null AND e.data.tier IN ['gold', 'premium']",
+		},
+		{
+			name:   "Time and json.Number",
+			query:  "WHERE e.time >= $since AND e.data.amount > $min",
+			params: Params{"since": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "min": json.Number("1e3")},
+			want:   "WHERE e.time >= '2024-01-01T00:00:00Z' AND e.data.amount > 1e3",
+		},
+		{
+			name:   "Placeholders in literals and escaped dollar",
+			query:  `WHERE e.data.price == '$price' AND e.data.currency == "$" AND e.data.code == '$$' AND e.data.amount == $$5`,
+			params: Params{},
+			want:   `WHERE e.data.price == '$price' AND e.data.currency == "$" AND e.data.code == '$$' AND e.data.amount == $5`,
+		},
+		{
+			name:    "Missing param",
+			query:   "WHERE e.data.email == $email",
+			params:  Params{"mail": "x"},
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported value",
+			query:   "WHERE e.data == $data",
+			params:  Params{"data": map[string]string{"a": "b"}},
+			wantErr: true,
+		},
+		{
+			name:    "NaN",
+			query:   "WHERE e.data.amount == $amount",
+			params:  Params{"amount": math.NaN()},
+			wantErr: true,
+		},
+		{
+			name:    "Unterminated literal",
+			query:   "WHERE e.data.email == 'x AND e.id == $id",
+			params:  Params{"id": "1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BindParams(tt.query, tt.params)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidQueryParam) {
+					t.Fatalf("BindParams() error = %v, want ErrInvalidQueryParam", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BindParams() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BindParams() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *QueryBuilder
+		want  string
+	}{
+		{
+			name:  "Minimal",
+			query: NewQuery("e"),
+			want:  "STREAM e FROM events",
+		},
+		{
+			name: "All clauses",
+			query: NewQuery("e").
+				Under("/conference/2024/registrations").
+				WhereEquals("e.type", "registration-created").
+				Where("e.data.seats > $seats OR e.data.vip == true").
+				GroupBy("e.data.ticketType").
+				Having("e.data.ticketType == $ticketType").
+				OrderBy("e.time DESC").
+				Limit(50).
+				Map("COUNT() < 50").
+				Bind(Params{"seats": 1, "ticketType": "premium"}),
+			want: "STREAM e FROM events" +
+				" WHERE (e.subject UNDER '/conference/2024/registrations') AND (e.type == 'registration-created') AND (e.data.seats > 1 OR e.data.vip == true)" +
+				" GROUP BY e.data.ticketType HAVING e.data.ticketType == 'premium'" +
+				" ORDER BY e.time DESC LIMIT 50 MAP COUNT() < 50",
+		},
+		{
+			name:  "Where in",
+			query: NewQuery("e").WhereIn("e.data.status", "open", "pending $1").Map("{ id: e.id }"),
+			want:  "STREAM e FROM events WHERE e.data.status IN ['open', 'pending $1'] MAP { id: e.id }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Build() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Invalid literal", func(t *testing.T) {
+		if _, err := NewQuery("e").WhereEquals("e.data", struct{}{}).Build(); !errors.Is(err, ErrInvalidQueryParam) {
+			t.Errorf("Build() error = %v, want ErrInvalidQueryParam", err)
+		}
+	})
+
+	t.Run("Precondition", func(t *testing.T) {
+		p, err := IsQueryResultTrueWithParams("STREAM e FROM events WHERE e.data.email == $email MAP COUNT() == 0", Params{"email": "a'b"})
+		if err != nil {
+			t.Fatalf("IsQueryResultTrueWithParams() error = %v", err)
+		}
+		if p.Payload["query"] != `STREAM e FROM events WHERE e.data.email == 'a\'b' MAP COUNT() == 0` || p.Validate() != nil {
+			t.Errorf("Unexpected precondition: %v", p)
+		}
+	})
+}
+
+func TestQWithParams_Mock(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		query = body["query"]
+		w.WriteHeader(200)
+		w.Write([]byte(`{"count":1}` + "\n"))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"})
+
+	results, err := client.Q("STREAM e FROM events WHERE e.data.email == $email MAP { count: COUNT() }", Params{"email": "john.doe@example.com"})
+	if err != nil {
+		t.Fatalf("Q() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result, got %d", len(results))
+	}
+	if query != "STREAM e FROM events WHERE e.data.email == 'john.doe@example.com' MAP { count: COUNT() }" {
+		t.Errorf("Unexpected query: %s", query)
+	}
+
+	query = ""
+	if _, err := client.QueryEvents("STREAM e FROM events WHERE e.id == $id", Params{}); !errors.Is(err, ErrInvalidQueryParam) {
+		t.Errorf("QueryEvents() error = %v, want ErrInvalidQueryParam", err)
+	}
+	if query != "" {
+		t.Errorf("Expected no request for a query with missing params, got %s", query)
+	}
+}
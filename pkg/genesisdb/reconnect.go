@@ -0,0 +1,166 @@
+package genesisdb
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy controls how ObserveWithReconnect re-establishes a dropped
+// observe connection. Zero values fall back to the defaults noted below.
+type ReconnectPolicy struct {
+	// InitialBackoff is the delay before the first reconnect attempt.
+	// Defaults to 500ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponentially growing delay. Defaults to 30s.
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of consecutive failed connections after
+	// which the subscription gives up. Zero means retry forever.
+	MaxAttempts int
+	// OnReconnect, if set, is called before every reconnect attempt with the
+	// attempt number and the error that ended the previous connection.
+	OnReconnect func(attempt int, err error)
+}
+
+const (
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// backoff returns the delay before the given reconnect attempt (starting at
+// 1).
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	return jitteredBackoff(p.InitialBackoff, p.MaxBackoff, defaultInitialBackoff, defaultMaxBackoff, attempt)
+}
+
+// jitteredBackoff returns the exponential delay for attempt (starting at 1),
+// capped at max, with jitter applied to its upper half. Non-positive initial
+// and max values fall back to the given defaults.
+func jitteredBackoff(initial, max, defaultInitial, defaultMax time.Duration, attempt int) time.Duration {
+	if initial <= 0 {
+		initial = defaultInitial
+	}
+	if max <= 0 {
+		max = defaultMax
+	}
+
+	delay := initial
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// ObserveWithReconnect is like Observe but reconnects whenever the connection
+// drops, waiting according to policy between attempts. After each reconnect
+// the observation resumes after the last delivered event, so every event is
+// delivered once. A nil policy uses the defaults. API errors that another
+// attempt would receive as well, such as 400, 401 or 404, end the
+// subscription instead; see IsRetryable.
+func (es *Genesisdb) ObserveWithReconnect(ctx context.Context, subject string, options *StreamOptions, policy *ReconnectPolicy) *Subscription {
+	if policy == nil {
+		policy = &ReconnectPolicy{}
+	}
+	sub := newSubscription(ctx, subscriptionBuffer)
+
+	go func() {
+		sub.finish(es.observeWithReconnect(sub.ctx, subject, options, policy, sub))
+	}()
+
+	return sub
+}
+
+func (es *Genesisdb) observeWithReconnect(ctx context.Context, subject string, options *StreamOptions, policy *ReconnectPolicy, sub *Subscription) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	attempt := 0
+	for {
+		before := sub.LastEventID()
+		if options != nil && options.UpperBound != "" && before == options.UpperBound {
+			// Everything up to the upper bound has been delivered
+			return ErrStreamEnded
+		}
+
+		err := es.observe(ctx, subject, resumeOptions(options, before), sub)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, errTokenRotated) {
+			continue
+		}
+		if errors.Is(err, ErrStreamEnded) && options != nil && options.UpperBound != "" {
+			// The server ends a bounded stream once it reached the bound,
+			// whether or not the bound event itself was delivered
+			return err
+		}
+
+		if !reconnectable(err) {
+			return err
+		}
+
+		// A connection that delivered events was healthy, so the next
+		// failure starts the backoff from scratch.
+		if sub.LastEventID() != before {
+			attempt = 0
+		}
+		attempt++
+		if err := policy.wait(ctx, attempt, err); err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until the given reconnect attempt after a connection that
+// ended with err is due. It returns err once MaxAttempts is exceeded, and the
+// context error if ctx is done first.
+func (p *ReconnectPolicy) wait(ctx context.Context, attempt int, err error) error {
+	if p.MaxAttempts > 0 && attempt > p.MaxAttempts {
+		return err
+	}
+
+	if p.OnReconnect != nil {
+		p.OnReconnect(attempt, err)
+	}
+
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reconnectable reports whether a connection that ended with err is worth
+// establishing again: it is unless the API rejected it with an error that
+// IsRetryable considers permanent.
+func reconnectable(err error) bool {
+	var apiErr *APIError
+	return !errors.As(err, &apiErr) || IsRetryable(err)
+}
+
+// resumeOptions returns the options for a connection that continues after
+// lastEventID. Until an event has been delivered the original options are
+// used unchanged.
+func resumeOptions(options *StreamOptions, lastEventID string) *StreamOptions {
+	if lastEventID == "" {
+		return options
+	}
+
+	resumed := StreamOptions{}
+	if options != nil {
+		resumed = *options
+	}
+	resumed.LowerBound = lastEventID
+	resumed.IncludeLowerBoundEvent = false
+	return &resumed
+}
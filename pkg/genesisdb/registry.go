@@ -0,0 +1,183 @@
+package genesisdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// UnknownTypePolicy decides what a Registry does with events whose type was
+// not registered.
+type UnknownTypePolicy int
+
+const (
+	// UnknownTypeKeepRaw delivers unknown events with their data decoded
+	// generically, as without a registry.
+	UnknownTypeKeepRaw UnknownTypePolicy = iota
+	// UnknownTypeSkip drops unknown events.
+	UnknownTypeSkip
+	// UnknownTypeError fails with ErrUnknownEventType.
+	UnknownTypeError
+)
+
+// ErrUnknownEventType is returned for events whose type is not registered
+// when the registry uses UnknownTypeError.
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// VersionFunc returns the schema version of an event.
+type VersionFunc func(event Event) int
+
+// DataVersion returns a VersionFunc that reads the version from the numeric
+// field of the event data with the given name. Events without the field
+// have version 0.
+func DataVersion(field string) VersionFunc {
+	return func(event Event) int {
+		data, ok := event.Data.(map[string]interface{})
+		if !ok {
+			return 0
+		}
+		switch version := data[field].(type) {
+		case float64:
+			return int(version)
+		case int:
+			return version
+		case int64:
+			return int(version)
+		case json.Number:
+			v, _ := version.Int64()
+			return int(v)
+		}
+		return 0
+	}
+}
+
+type registryKey struct {
+	eventType string
+	version   int
+}
+
+// Registry maps event types to Go types. A client configured with
+// WithRegistry decodes the data of every streamed or observed event into the
+// registered type, so Event.Data holds a value of that type.
+type Registry struct {
+	mu      sync.RWMutex
+	types   map[registryKey]reflect.Type
+	unknown UnknownTypePolicy
+	version VersionFunc
+}
+
+// NewRegistry creates an empty registry that handles unregistered event
+// types according to unknown. Versions are read from the "schemaVersion"
+// field of the event data; use SetVersionFunc to change that.
+func NewRegistry(unknown UnknownTypePolicy) *Registry {
+	return &Registry{
+		types:   make(map[registryKey]reflect.Type),
+		unknown: unknown,
+		version: DataVersion("schemaVersion"),
+	}
+}
+
+// SetVersionFunc changes how the registry determines event versions.
+func (r *Registry) SetVersionFunc(fn VersionFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version = fn
+}
+
+// Register maps eventType to T for every version that has no type
+// registered with RegisterVersion.
+func Register[T any](r *Registry, eventType string) {
+	RegisterVersion[T](r, eventType, 0)
+}
+
+// RegisterVersion maps the given version of eventType to T.
+func RegisterVersion[T any](r *Registry, eventType string, version int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[registryKey{eventType, version}] = reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Decode replaces the data of event with a value of its registered type. It
+// reports false if the event should be skipped.
+func (r *Registry) Decode(event *Event) (bool, error) {
+	r.mu.RLock()
+	version := r.version(*event)
+	t, ok := r.types[registryKey{event.Type, version}]
+	if !ok {
+		t, ok = r.types[registryKey{event.Type, 0}]
+	}
+	unknown := r.unknown
+	r.mu.RUnlock()
+
+	if !ok {
+		switch unknown {
+		case UnknownTypeSkip:
+			return false, nil
+		case UnknownTypeError:
+			return false, fmt.Errorf("%w: %s (version %d)", ErrUnknownEventType, event.Type, version)
+		}
+		return true, nil
+	}
+
+	raw := event.rawData
+	if raw == nil {
+		var err error
+		if raw, err = json.Marshal(event.Data); err != nil {
+			return false, fmt.Errorf("error encoding event data: %w", err)
+		}
+	}
+
+	value := reflect.New(t)
+	if err := json.Unmarshal(raw, value.Interface()); err != nil {
+		return false, fmt.Errorf("error decoding data of event %s into %s: %w", event.ID, t, err)
+	}
+	event.Data = value.Elem().Interface()
+	return true, nil
+}
+
+// WithRegistry decodes the data of streamed and observed events into the
+// types registered in registry.
+func WithRegistry(registry *Registry) Option {
+	return func(es *Genesisdb) {
+		es.registry = registry
+	}
+}
+
+// prepareEvents turns an event received from the API into the events to
+// deliver: defaults are filled in, upcasters applied and the data decoded
+// into registered types. The result may be empty.
+func (es *Genesisdb) prepareEvents(event Event) ([]Event, error) {
+	es.populateDefaults(&event)
+
+	events := []Event{event}
+	if es.upcasters != nil {
+		var err error
+		if events, err = es.upcasters.Upcast(event); err != nil {
+			return nil, err
+		}
+	}
+
+	prepared := events
+	if es.registry != nil {
+		prepared = events[:0]
+		for _, event := range events {
+			keep, err := es.registry.Decode(&event)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				prepared = append(prepared, event)
+			}
+		}
+	}
+
+	for i := range prepared {
+		prepared[i].resumeID = ""
+	}
+	if len(prepared) > 0 {
+		prepared[len(prepared)-1].resumeID = event.ID
+	}
+	return prepared, nil
+}
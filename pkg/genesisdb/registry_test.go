@@ -0,0 +1,142 @@
+package genesisdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type customerAddedV2 struct {
+	Name string `json:"name"`
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	registry := NewRegistry(UnknownTypeKeepRaw)
+	Register[customerAdded](registry, "customer-added")
+	RegisterVersion[customerAddedV2](registry, "customer-added", 2)
+
+	tests := []struct {
+		name  string
+		event Event
+		want  interface{}
+	}{
+		{
+			name:  "Unversioned",
+			event: Event{Type: "customer-added", Data: map[string]interface{}{"firstName": "Bruce"}},
+			want:  customerAdded{FirstName: "Bruce"},
+		},
+		{
+			name:  "Registered version",
+			event: Event{Type: "customer-added", Data: map[string]interface{}{"schemaVersion": 2.0, "name": "Bruce Wayne"}},
+			want:  customerAddedV2{Name: "Bruce Wayne"},
+		},
+		{
+			name:  "Unregistered version falls back",
+			event: Event{Type: "customer-added", Data: map[string]interface{}{"schemaVersion": 3.0, "firstName": "Bruce"}},
+			want:  customerAdded{FirstName: "Bruce"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := tt.event
+			keep, err := registry.Decode(&event)
+			if err != nil || !keep {
+				t.Fatalf("Decode() = %v, %v", keep, err)
+			}
+			if event.Data != tt.want {
+				t.Errorf("Data = %#v, want %#v", event.Data, tt.want)
+			}
+		})
+	}
+
+	t.Run("Unknown type policies", func(t *testing.T) {
+		data := map[string]interface{}{"sku": "tumbler"}
+
+		event := Event{Type: "article-added", Data: data}
+		if keep, err := registry.Decode(&event); !keep || err != nil {
+			t.Errorf("KeepRaw: Decode() = %v, %v", keep, err)
+		}
+
+		event = Event{Type: "article-added", Data: data}
+		if keep, err := NewRegistry(UnknownTypeSkip).Decode(&event); keep || err != nil {
+			t.Errorf("Skip: Decode() = %v, %v", keep, err)
+		}
+
+		event = Event{Type: "article-added", Data: data}
+		if _, err := NewRegistry(UnknownTypeError).Decode(&event); !errors.Is(err, ErrUnknownEventType) {
+			t.Errorf("Error: Decode() error = %v, want ErrUnknownEventType", err)
+		}
+	})
+}
+
+func TestRegistry_Mock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte(strings.Join([]string{
+			`{"id":"1","subject":"/customer","type":"customer-added","data":{"firstName":"Bruce"}}`,
+			`{"id":"2","subject":"/article","type":"article-added","data":{"sku":"tumbler"}}`,
+			`{"id":"3","subject":"/customer","type":"customer-added","data":{"firstName":"Alfred"}}`,
+		}, "\n") + "\n"))
+	}))
+	defer server.Close()
+
+	registry := NewRegistry(UnknownTypeSkip)
+	Register[customerAdded](registry, "customer-added")
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"}, WithRegistry(registry))
+
+	t.Run("Stream", func(t *testing.T) {
+		events, err := client.StreamEvents("/", nil)
+		if err != nil {
+			t.Fatalf("StreamEvents() error = %v", err)
+		}
+		if len(events) != 2 {
+			t.Fatalf("Expected 2 events, got %d", len(events))
+		}
+		if customer, ok := events[1].Data.(customerAdded); !ok || customer.FirstName != "Alfred" {
+			t.Errorf("Unexpected data: %#v", events[1].Data)
+		}
+	})
+
+	t.Run("Observe", func(t *testing.T) {
+		sub := client.Observe(context.Background(), "/", nil)
+		defer sub.Close()
+
+		var events []Event
+		for event := range sub.Events() {
+			events = append(events, event)
+		}
+		if len(events) != 2 {
+			t.Fatalf("Expected 2 events, got %d", len(events))
+		}
+		if _, ok := events[0].Data.(customerAdded); !ok {
+			t.Errorf("Unexpected data: %#v", events[0].Data)
+		}
+	})
+
+	t.Run("Unknown type error", func(t *testing.T) {
+		strict := NewRegistry(UnknownTypeError)
+		Register[customerAdded](strict, "customer-added")
+		client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"}, WithRegistry(strict))
+
+		if _, err := client.StreamEvents("/", nil); !errors.Is(err, ErrUnknownEventType) {
+			t.Errorf("StreamEvents() error = %v, want ErrUnknownEventType", err)
+		}
+
+		sub := client.Observe(context.Background(), "/", nil)
+		defer sub.Close()
+		select {
+		case err := <-sub.Errors():
+			if !errors.Is(err, ErrUnknownEventType) {
+				t.Errorf("Errors() = %v, want ErrUnknownEventType", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for error")
+		}
+	})
+}
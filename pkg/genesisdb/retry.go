@@ -0,0 +1,195 @@
+package genesisdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. It is enabled with
+// WithRetryPolicy; without it every request is attempted once.
+//
+// Commits are only retried after the client has verified, using the event
+// IDs assigned before sending, that the failed attempt was not applied.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first.
+	// Defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 200ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponentially growing delay, and the delay
+	// requested by a Retry-After header. Defaults to 5s.
+	MaxBackoff time.Duration
+	// Retryable decides whether err is worth retrying. Defaults to
+	// IsRetryable.
+	Retryable func(err error) bool
+	// IgnoreRetryAfter disables waiting for the duration given in a
+	// Retry-After response header instead of the computed backoff.
+	IgnoreRetryAfter bool
+}
+
+const (
+	defaultRetryAttempts       = 3
+	defaultRetryInitialBackoff = 200 * time.Millisecond
+	defaultRetryMaxBackoff     = 5 * time.Second
+)
+
+// errAlreadyApplied stops a retry loop once a previous attempt turned out to
+// have taken effect.
+var errAlreadyApplied = errors.New("request already applied")
+
+// WithRetryPolicy retries failed requests according to policy.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(es *Genesisdb) {
+		es.retryPolicy = policy
+	}
+}
+
+// IsRetryable reports whether err is a transient failure: a transport error
+// or an API error with status 408, 429, 502, 503 or 504.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch apiErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return errors.Is(apiErr, ErrServerUnavailable)
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return defaultRetryAttempts
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// delay returns how long to wait before retrying after the given attempt
+// failed with err.
+func (p *RetryPolicy) delay(attempt int, err error) time.Duration {
+	if !p.IgnoreRetryAfter {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			if d, ok := parseRetryAfter(apiErr.Header.Get("Retry-After")); ok {
+				// A server asking for a longer pause would stall the
+				// request far beyond what the policy allows
+				max := p.MaxBackoff
+				if max <= 0 {
+					max = defaultRetryMaxBackoff
+				}
+				if d > max {
+					d = max
+				}
+				return d
+			}
+		}
+	}
+	return jitteredBackoff(p.InitialBackoff, p.MaxBackoff, defaultRetryInitialBackoff, defaultRetryMaxBackoff, attempt)
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// doWithRetry sends req like do and retries it according to the client's
+// retry policy. If applied is set it is called before every retry; when it
+// reports that a previous attempt took effect, errAlreadyApplied is returned
+// instead of retrying. If it cannot tell, the original error is returned.
+func (es *Genesisdb) doWithRetry(req *http.Request, applied func() (bool, error)) (*http.Response, error) {
+	policy := es.retryPolicy
+	ctx := req.Context()
+	refreshed := false
+
+	for attempt := 1; ; attempt++ {
+		resp, err := es.send(req)
+		if err == nil {
+			return resp, nil
+		}
+
+		// A rejected token is refreshed and the request retried once,
+		// without counting against the retry policy
+		var apiErr *APIError
+		if !refreshed && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			refreshed = true
+			used := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if token := es.refreshToken(ctx, used); token != "" {
+				if req, err = rewind(req); err != nil {
+					return nil, err
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+				attempt--
+				continue
+			}
+		}
+		if policy == nil || attempt >= policy.maxAttempts() || ctx.Err() != nil || !policy.retryable(err) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(policy.delay(attempt, err))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		if applied != nil {
+			ok, checkErr := applied()
+			if checkErr != nil {
+				return nil, err
+			}
+			if ok {
+				return nil, errAlreadyApplied
+			}
+		}
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rewind returns a copy of req whose body can be sent again.
+func rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.GetBody == nil {
+		return clone, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
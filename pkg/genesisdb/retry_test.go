@@ -0,0 +1,181 @@
+package genesisdb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Mock(t *testing.T) {
+	config := &Config{
+		APIVersion: "v1",
+		AuthToken:  "test-token",
+	}
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	t.Run("Retries transient failures", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts < 3 {
+				w.WriteHeader(503)
+				return
+			}
+			w.WriteHeader(200)
+			w.Write([]byte("pong"))
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg, WithRetryPolicy(policy))
+
+		if _, err := client.Ping(); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+		if attempts != 3 {
+			t.Errorf("attempts = %d, want 3", attempts)
+		}
+	})
+
+	t.Run("Does not retry client errors", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(400)
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg, WithRetryPolicy(policy))
+
+		if _, err := client.StreamEvents("/test", nil); err == nil {
+			t.Fatal("StreamEvents() should return error")
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	})
+
+	t.Run("Retried commit resends the same body", func(t *testing.T) {
+		var commits []CommitRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/commit":
+				var req CommitRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				commits = append(commits, req)
+				if len(commits) == 1 {
+					w.WriteHeader(503)
+					return
+				}
+			case "/api/v1/stream":
+				// The failed attempt was not applied
+			}
+			w.WriteHeader(200)
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg, WithRetryPolicy(policy))
+
+		if err := client.CommitEvents([]Event{{Subject: "/test", Type: "test.event"}}); err != nil {
+			t.Fatalf("CommitEvents() error = %v", err)
+		}
+		if len(commits) != 2 {
+			t.Fatalf("commits = %d, want 2", len(commits))
+		}
+		if commits[0].Events[0].ID == "" || commits[0].Events[0].ID != commits[1].Events[0].ID {
+			t.Errorf("Retried commit should keep event ID, got %s and %s", commits[0].Events[0].ID, commits[1].Events[0].ID)
+		}
+	})
+
+	t.Run("Applied commit is not retried", func(t *testing.T) {
+		var stored []Event
+		commits := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/commit":
+				commits++
+				var req CommitRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				stored = append(stored, req.Events...)
+				// Applied, but the response is lost
+				w.WriteHeader(502)
+			case "/api/v1/stream":
+				var req StreamRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				if req.Options == nil || req.Options.LowerBound != stored[0].ID || !req.Options.IncludeLowerBoundEvent {
+					t.Errorf("Unexpected stream options: %+v", req.Options)
+				}
+				w.WriteHeader(200)
+				for _, event := range stored {
+					eventJSON, _ := json.Marshal(event)
+					w.Write([]byte(string(eventJSON) + "\n"))
+				}
+			}
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg, WithRetryPolicy(policy))
+
+		if err := client.CommitEvents([]Event{{Subject: "/test", Type: "test.event"}}); err != nil {
+			t.Fatalf("CommitEvents() error = %v", err)
+		}
+		if commits != 1 {
+			t.Errorf("commits = %d, want 1", commits)
+		}
+	})
+
+	t.Run("Gives up after MaxAttempts", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(503)
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg, WithRetryPolicy(policy))
+
+		if _, err := client.Q("FROM e IN events"); !errors.Is(err, ErrServerUnavailable) {
+			t.Errorf("Q() error = %v, want ErrServerUnavailable", err)
+		}
+		if attempts != 3 {
+			t.Errorf("attempts = %d, want 3", attempts)
+		}
+	})
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 2 * time.Minute}
+
+	err := &APIError{StatusCode: 429, Header: http.Header{"Retry-After": []string{"7"}}}
+	if d := policy.delay(1, err); d != 7*time.Second {
+		t.Errorf("delay() = %v, want 7s from Retry-After", d)
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	err = &APIError{StatusCode: 503, Header: http.Header{"Retry-After": []string{date}}}
+	if d := policy.delay(1, err); d < 58*time.Second || d > time.Minute {
+		t.Errorf("delay() = %v, want about 1m from Retry-After date", d)
+	}
+
+	err = &APIError{StatusCode: 503, Header: http.Header{"Retry-After": []string{"3600"}}}
+	if d := policy.delay(1, err); d != 2*time.Minute {
+		t.Errorf("delay() = %v, want Retry-After capped at MaxBackoff", d)
+	}
+
+	policy.IgnoreRetryAfter = true
+	if d := policy.delay(1, err); d > time.Second {
+		t.Errorf("delay() = %v, want backoff when Retry-After is ignored", d)
+	}
+}
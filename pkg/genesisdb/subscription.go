@@ -0,0 +1,186 @@
+package genesisdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSubscriptionClosed is reported by Subscription.Err after Close was called.
+	ErrSubscriptionClosed = errors.New("subscription closed")
+	// ErrStreamEnded is reported by Subscription.Err when the server ended the observe stream.
+	ErrStreamEnded = errors.New("observe stream ended by server")
+)
+
+// Subscription is a running observation started by Observe. Events are
+// delivered on Events until the observation ends, after which Done is closed
+// and Err reports the reason. A consumer that falls behind holds the
+// observation back: no event is dropped and the subscription waits for it
+// for as long as it takes.
+type Subscription struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	events chan Event
+	errors chan error
+	done   chan struct{}
+
+	mu          sync.Mutex
+	err         error
+	closed      bool
+	lastEventID string
+
+	// recent remembers the IDs of recently delivered events to drop
+	// duplicates, if set
+	recent *recentIDs
+	// sendTimeout, if set, ends the subscription when an event has not been
+	// received from Events within it
+	sendTimeout time.Duration
+}
+
+// subscriptionBuffer is the number of events a subscription reads ahead of
+// its consumer.
+const subscriptionBuffer = 100
+
+func newSubscription(parent context.Context, buffer int) *Subscription {
+	ctx, cancel := context.WithCancel(parent)
+	return &Subscription{
+		ctx:    ctx,
+		cancel: cancel,
+		events: make(chan Event, buffer),
+		errors: make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// Events returns the channel on which observed events are delivered. It is
+// closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Errors returns the channel on which errors are delivered, including lines
+// that could not be parsed and the error that ended the subscription. It
+// should be read alongside Events and is closed when the subscription ends.
+func (s *Subscription) Errors() <-chan error {
+	return s.errors
+}
+
+// Done returns a channel that is closed once the subscription has ended and
+// its connection and goroutine have been released.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns nil while the subscription is running. Afterwards it reports
+// why it ended: ErrSubscriptionClosed after Close, the context error if the
+// parent context was canceled, ErrStreamEnded if the server closed the
+// stream, or the error that aborted the observation.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// LastEventID returns the ID of the most recent event received from the API
+// that has been delivered on Events, or an empty string if none has been
+// delivered yet. With upcasters the delivered events may carry other IDs;
+// Event.ResumeID tells the received ID of each.
+func (s *Subscription) LastEventID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastEventID
+}
+
+// Close stops the subscription and waits until its connection has been
+// closed and its goroutine has exited. It is safe to call Close more than
+// once.
+func (s *Subscription) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *Subscription) send(event Event) error {
+	if s.sendTimeout <= 0 {
+		select {
+		case s.events <- event:
+			return nil
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+
+	timer := time.NewTimer(s.sendTimeout)
+	defer timer.Stop()
+	select {
+	case s.events <- event:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-timer.C:
+		return fmt.Errorf("timeout sending event to channel")
+	}
+}
+
+// delivered records that everything derived from the received event with
+// the given ID has been sent.
+func (s *Subscription) delivered(id string) {
+	s.mu.Lock()
+	s.lastEventID = id
+	if s.recent != nil {
+		s.recent.add(id)
+	}
+	s.mu.Unlock()
+}
+
+// duplicate reports whether the received event with the given ID has
+// already been delivered.
+func (s *Subscription) duplicate(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == s.lastEventID {
+		return true
+	}
+	return s.recent != nil && s.recent.contains(id)
+}
+
+func (s *Subscription) sendError(err error) {
+	select {
+	case s.errors <- err:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *Subscription) finish(err error) {
+	s.mu.Lock()
+	switch {
+	case s.closed:
+		err = ErrSubscriptionClosed
+	case s.ctx.Err() != nil:
+		err = s.ctx.Err()
+	case err != nil && !errors.Is(err, ErrStreamEnded):
+		select {
+		case s.errors <- err:
+		default:
+		}
+	}
+	s.err = err
+	s.mu.Unlock()
+
+	s.cancel()
+	close(s.events)
+	close(s.errors)
+	close(s.done)
+}
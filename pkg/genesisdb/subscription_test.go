@@ -0,0 +1,298 @@
+package genesisdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newObserveServer(hold bool, closed chan<- struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eventJSON, _ := json.Marshal(Event{ID: "1", Subject: "/test", Type: "test.event"})
+		w.WriteHeader(200)
+		w.Write([]byte(string(eventJSON) + "\n"))
+		w.(http.Flusher).Flush()
+		if hold {
+			<-r.Context().Done()
+			if closed != nil {
+				close(closed)
+			}
+		}
+	}))
+}
+
+func TestSubscription_Mock(t *testing.T) {
+	config := &Config{
+		APIVersion: "v1",
+		AuthToken:  "test-token",
+	}
+
+	t.Run("Close releases connection", func(t *testing.T) {
+		closed := make(chan struct{})
+		server := newObserveServer(true, closed)
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		sub := client.Observe(context.Background(), "/test", nil)
+
+		select {
+		case event := <-sub.Events():
+			if event.ID != "1" {
+				t.Errorf("Unexpected event ID: %s", event.ID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for event")
+		}
+
+		if err := sub.Err(); err != nil {
+			t.Errorf("Err() = %v while running, want nil", err)
+		}
+
+		sub.Close()
+
+		select {
+		case <-sub.Done():
+		default:
+			t.Fatal("Done() should be closed after Close returns")
+		}
+		if !errors.Is(sub.Err(), ErrSubscriptionClosed) {
+			t.Errorf("Err() = %v, want ErrSubscriptionClosed", sub.Err())
+		}
+		if _, ok := <-sub.Events(); ok {
+			t.Error("Events() should be closed after Close")
+		}
+
+		select {
+		case <-closed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Server connection was not closed")
+		}
+
+		sub.Close()
+	})
+
+	t.Run("Server ends stream", func(t *testing.T) {
+		server := newObserveServer(false, nil)
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		sub := client.Observe(context.Background(), "/test", nil)
+		for range sub.Events() {
+		}
+		<-sub.Done()
+
+		if !errors.Is(sub.Err(), ErrStreamEnded) {
+			t.Errorf("Err() = %v, want ErrStreamEnded", sub.Err())
+		}
+		if err, ok := <-sub.Errors(); ok {
+			t.Errorf("Unexpected error on Errors(): %v", err)
+		}
+	})
+
+	t.Run("Parent context canceled", func(t *testing.T) {
+		server := newObserveServer(true, nil)
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sub := client.Observe(ctx, "/test", nil)
+		<-sub.Events()
+		cancel()
+
+		select {
+		case <-sub.Done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for subscription to end")
+		}
+		if !errors.Is(sub.Err(), context.Canceled) {
+			t.Errorf("Err() = %v, want context.Canceled", sub.Err())
+		}
+	})
+
+	t.Run("API error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(500)
+			w.Write([]byte("Internal Server Error"))
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		sub := client.Observe(context.Background(), "/test", nil)
+		<-sub.Done()
+
+		if sub.Err() == nil || !strings.Contains(sub.Err().Error(), "500") {
+			t.Errorf("Err() = %v, want API error with status code", sub.Err())
+		}
+		if err := <-sub.Errors(); err == nil || !strings.Contains(err.Error(), "500") {
+			t.Errorf("Errors() = %v, want API error with status code", err)
+		}
+	})
+}
+
+func TestObserveWithReconnect_Mock(t *testing.T) {
+	config := &Config{
+		APIVersion: "v1",
+		AuthToken:  "test-token",
+	}
+	policy := &ReconnectPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+	t.Run("Resumes after last delivered event", func(t *testing.T) {
+		var requests []StreamRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req StreamRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			requests = append(requests, req)
+
+			w.WriteHeader(200)
+			ids := []string{"1", "2"}
+			if len(requests) > 1 {
+				ids = []string{"2", "3"}
+			}
+			for _, id := range ids {
+				eventJSON, _ := json.Marshal(Event{ID: id, Subject: "/test", Type: "test.event"})
+				w.Write([]byte(string(eventJSON) + "\n"))
+			}
+			w.(http.Flusher).Flush()
+			if len(requests) > 1 {
+				<-r.Context().Done()
+			}
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		sub := client.ObserveWithReconnect(context.Background(), "/test", &StreamOptions{LatestByEventType: "test.event"}, policy)
+		defer sub.Close()
+
+		var ids []string
+		for len(ids) < 3 {
+			select {
+			case event := <-sub.Events():
+				ids = append(ids, event.ID)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("Timeout waiting for events, got %v", ids)
+			}
+		}
+		if strings.Join(ids, ",") != "1,2,3" {
+			t.Errorf("Received events %v, want [1 2 3]", ids)
+		}
+
+		sub.Close()
+		if len(requests) != 2 {
+			t.Fatalf("Expected 2 requests, got %d", len(requests))
+		}
+		resumed := requests[1].Options
+		if resumed == nil || resumed.LowerBound != "2" || resumed.IncludeLowerBoundEvent {
+			t.Errorf("Unexpected resume options: %+v", resumed)
+		}
+		if resumed != nil && resumed.LatestByEventType != "test.event" {
+			t.Errorf("Resume should keep LatestByEventType, got %+v", resumed)
+		}
+		if sub.LastEventID() != "3" {
+			t.Errorf("LastEventID() = %s, want 3", sub.LastEventID())
+		}
+	})
+
+	t.Run("Gives up after MaxAttempts", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(503)
+			w.Write([]byte("Service Unavailable"))
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg)
+
+		var reconnects int
+		p := *policy
+		p.MaxAttempts = 2
+		p.OnReconnect = func(attempt int, err error) {
+			reconnects = attempt
+		}
+
+		sub := client.ObserveWithReconnect(context.Background(), "/test", nil, &p)
+		select {
+		case <-sub.Done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for subscription to give up")
+		}
+
+		if sub.Err() == nil || !strings.Contains(sub.Err().Error(), "503") {
+			t.Errorf("Err() = %v, want API error with status code", sub.Err())
+		}
+		if attempts != 3 || reconnects != 2 {
+			t.Errorf("attempts = %d, reconnects = %d, want 3 and 2", attempts, reconnects)
+		}
+	})
+	t.Run("Stops on permanent errors", func(t *testing.T) {
+		for _, status := range []int{400, 401, 404} {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.WriteHeader(status)
+			}))
+
+			cfg := *config
+			cfg.APIURL = server.URL
+			client, _ := NewClient(&cfg)
+
+			sub := client.ObserveWithReconnect(context.Background(), "/test", nil, policy)
+			select {
+			case <-sub.Done():
+			case <-time.After(2 * time.Second):
+				t.Fatalf("Timeout waiting for subscription to stop on %d", status)
+			}
+			server.Close()
+
+			var apiErr *APIError
+			if !errors.As(sub.Err(), &apiErr) || apiErr.StatusCode != status || attempts != 1 {
+				t.Errorf("Err() = %v after %d attempts, want API error %d after 1", sub.Err(), attempts, status)
+			}
+		}
+	})
+}
+
+func TestReconnectPolicy_Backoff(t *testing.T) {
+	policy := &ReconnectPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{10, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			delay := policy.backoff(tt.attempt)
+			if delay < tt.max/2 || delay > tt.max {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", tt.attempt, delay, tt.max/2, tt.max)
+			}
+		}
+	}
+}
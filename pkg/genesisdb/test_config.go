@@ -1,9 +1,7 @@
 package genesisdb
 
 import (
-	"bufio"
 	"os"
-	"strings"
 )
 
 // TestConfig holds configuration for tests
@@ -44,30 +42,15 @@ func GetTestConfig() *TestConfig {
 
 // loadEnvFile loads environment variables from .env file
 func loadEnvFile() {
-	file, err := os.Open(".env")
+	values, err := readEnvFile(".env")
 	if err != nil {
-		return // .env file doesn't exist, that's OK
+		return
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
 
+	for key, value := range values {
 		// Only set if not already set
 		if os.Getenv(key) == "" {
 			os.Setenv(key, value)
 		}
 	}
-}
\ No newline at end of file
+}
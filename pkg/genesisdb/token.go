@@ -0,0 +1,207 @@
+package genesisdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the bearer token for a request. It is consulted for
+// every request, so implementations should cache the token themselves.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// RefreshableTokenSource is a TokenSource that can be asked for a fresh
+// token after the API rejected the current one with 401 Unauthorized.
+type RefreshableTokenSource interface {
+	TokenSource
+	Refresh(ctx context.Context) (string, error)
+}
+
+// RotatingTokenSource is a TokenSource that reports when its token changes.
+// Running observations reconnect with the new token.
+type RotatingTokenSource interface {
+	TokenSource
+	// Rotated returns a channel that is closed the next time the token
+	// changes.
+	Rotated() <-chan struct{}
+}
+
+// WithTokenSource authenticates requests with tokens from source instead of
+// Config.AuthToken, which may then be left empty.
+func WithTokenSource(source TokenSource) Option {
+	return func(es *Genesisdb) {
+		es.tokenSource = source
+	}
+}
+
+// StaticToken returns a TokenSource that always supplies token.
+func StaticToken(token string) TokenSource {
+	return staticToken(token)
+}
+
+type staticToken string
+
+func (t staticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// TokenFunc adapts a function to a TokenSource. The function is called for
+// every request and again after a 401 response.
+type TokenFunc func(ctx context.Context) (string, error)
+
+func (f TokenFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// FileTokenSource reads the token from a file, such as a mounted Kubernetes
+// secret, and picks up changes to it. It implements RefreshableTokenSource
+// and RotatingTokenSource.
+type FileTokenSource struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	rotated chan struct{}
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewFileTokenSource reads the token at path and checks the file for changes
+// every pollInterval until Close is called. A pollInterval of zero disables
+// polling; the file is then only re-read after the API rejected the token.
+func NewFileTokenSource(path string, pollInterval time.Duration) (*FileTokenSource, error) {
+	s := &FileTokenSource{
+		path:    path,
+		rotated: make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	if _, err := s.reload(true); err != nil {
+		return nil, err
+	}
+
+	if pollInterval > 0 {
+		go s.poll(pollInterval)
+	}
+
+	return s, nil
+}
+
+func (s *FileTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token, nil
+}
+
+// Refresh re-reads the token file.
+func (s *FileTokenSource) Refresh(ctx context.Context) (string, error) {
+	return s.reload(true)
+}
+
+func (s *FileTokenSource) Rotated() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rotated
+}
+
+// Close stops polling the file.
+func (s *FileTokenSource) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *FileTokenSource) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.reload(false)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// reload reads the token file if force is set or its modification time
+// changed, and signals a rotation if the token differs.
+func (s *FileTokenSource) reload(force bool) (string, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return "", fmt.Errorf("error reading token file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !force && info.ModTime().Equal(s.modTime) {
+		return s.token, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("error reading token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("token file is empty")
+	}
+
+	s.modTime = info.ModTime()
+	if token != s.token {
+		if s.token != "" {
+			close(s.rotated)
+			s.rotated = make(chan struct{})
+		}
+		s.token = token
+	}
+	return s.token, nil
+}
+
+// errTokenRotated ends an observe connection whose token was replaced.
+var errTokenRotated = errors.New("auth token rotated")
+
+// refreshToken returns a token to retry with after used was rejected, or an
+// empty string if the token source has nothing newer.
+func (es *Genesisdb) refreshToken(ctx context.Context, used string) string {
+	var token string
+	var err error
+	if refreshable, ok := es.tokenSource.(RefreshableTokenSource); ok {
+		token, err = refreshable.Refresh(ctx)
+	} else {
+		token, err = es.tokenSource.Token(ctx)
+	}
+	if err != nil || token == "" || token == used {
+		return ""
+	}
+	return token
+}
+
+// watchTokenRotation cancels a connection with errTokenRotated when the
+// client's token source rotates. The returned function stops watching.
+func (es *Genesisdb) watchTokenRotation(ctx context.Context, cancel context.CancelCauseFunc) func() {
+	rotating, ok := es.tokenSource.(RotatingTokenSource)
+	if !ok {
+		return func() {}
+	}
+
+	rotated := rotating.Rotated()
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-rotated:
+			cancel(errTokenRotated)
+		case <-ctx.Done():
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
@@ -0,0 +1,198 @@
+package genesisdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenSource_Mock(t *testing.T) {
+	config := &Config{
+		APIVersion: "v1",
+	}
+
+	t.Run("Token source replaces AuthToken", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if auth := r.Header.Get("Authorization"); auth != "Bearer from-func" {
+				t.Errorf("Unexpected Authorization header: %s", auth)
+			}
+			w.WriteHeader(200)
+		}))
+		defer server.Close()
+
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, err := NewClient(&cfg, WithTokenSource(TokenFunc(func(ctx context.Context) (string, error) {
+			return "from-func", nil
+		})))
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+
+		if _, err := client.Ping(); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+	})
+
+	t.Run("Token source error", func(t *testing.T) {
+		cfg := *config
+		cfg.APIURL = "http://localhost:8080"
+		client, _ := NewClient(&cfg, WithTokenSource(TokenFunc(func(ctx context.Context) (string, error) {
+			return "", errors.New("vault unavailable")
+		})))
+
+		if _, err := client.Ping(); err == nil {
+			t.Error("Ping() should return token source error")
+		}
+	})
+
+	t.Run("Retry once on 401 after refresh", func(t *testing.T) {
+		var tokens []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokens = append(tokens, r.Header.Get("Authorization"))
+			if r.Header.Get("Authorization") != "Bearer new" {
+				w.WriteHeader(401)
+				return
+			}
+			w.WriteHeader(200)
+		}))
+		defer server.Close()
+
+		token := "old"
+		cfg := *config
+		cfg.APIURL = server.URL
+		client, _ := NewClient(&cfg, WithTokenSource(TokenFunc(func(ctx context.Context) (string, error) {
+			return token, nil
+		})))
+
+		// The source has nothing newer, so the 401 is returned
+		if err := client.EraseData("/test"); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("EraseData() error = %v, want ErrUnauthorized", err)
+		}
+		if len(tokens) != 1 {
+			t.Fatalf("Expected 1 request without a new token, got %d", len(tokens))
+		}
+
+		tokens = nil
+		calls := 0
+		client, _ = NewClient(&cfg, WithTokenSource(TokenFunc(func(ctx context.Context) (string, error) {
+			calls++
+			if calls == 1 {
+				return "old", nil
+			}
+			return "new", nil
+		})))
+
+		if err := client.EraseData("/test"); err != nil {
+			t.Fatalf("EraseData() error = %v", err)
+		}
+		if len(tokens) != 2 || tokens[0] != "Bearer old" || tokens[1] != "Bearer new" {
+			t.Errorf("Unexpected tokens: %v", tokens)
+		}
+	})
+}
+
+func TestFileTokenSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("first\n"), 0o600)
+
+	source, err := NewFileTokenSource(path, 0)
+	if err != nil {
+		t.Fatalf("NewFileTokenSource() error = %v", err)
+	}
+	defer source.Close()
+
+	if token, _ := source.Token(context.Background()); token != "first" {
+		t.Errorf("Token() = %q, want first", token)
+	}
+
+	rotated := source.Rotated()
+	os.WriteFile(path, []byte("second\n"), 0o600)
+
+	if token, err := source.Refresh(context.Background()); err != nil || token != "second" {
+		t.Errorf("Refresh() = %q, %v, want second", token, err)
+	}
+	select {
+	case <-rotated:
+	default:
+		t.Error("Rotated() should be closed after the token changed")
+	}
+
+	if _, err := NewFileTokenSource(filepath.Join(t.TempDir(), "missing"), 0); err == nil {
+		t.Error("NewFileTokenSource() should fail for a missing file")
+	}
+}
+
+func TestObserveTokenRotation_Mock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("first"), 0o600)
+
+	source, err := NewFileTokenSource(path, 0)
+	if err != nil {
+		t.Fatalf("NewFileTokenSource() error = %v", err)
+	}
+	defer source.Close()
+
+	var mu sync.Mutex
+	var requests []*StreamRequest
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req StreamRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requests = append(requests, &req)
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		id := "1"
+		if len(requests) > 1 {
+			id = "2"
+		}
+		mu.Unlock()
+
+		eventJSON, _ := json.Marshal(Event{ID: id, Subject: "/test", Type: "test.event"})
+		w.WriteHeader(200)
+		w.Write([]byte(string(eventJSON) + "\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1"}, WithTokenSource(source))
+
+	sub := client.Observe(context.Background(), "/test", nil)
+	defer sub.Close()
+
+	if event := <-sub.Events(); event.ID != "1" {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+
+	os.WriteFile(path, []byte("second"), 0o600)
+	source.Refresh(context.Background())
+
+	select {
+	case event := <-sub.Events():
+		if event.ID != "2" {
+			t.Errorf("Unexpected event after rotation: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Observation did not reconnect after token rotation")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tokens) != 2 || tokens[1] != "Bearer second" {
+		t.Errorf("Unexpected tokens: %v", tokens)
+	}
+	if requests[1].Options == nil || requests[1].Options.LowerBound != "1" {
+		t.Errorf("Reconnect should resume after event 1, got %+v", requests[1].Options)
+	}
+	if sub.Err() != nil {
+		t.Errorf("Err() = %v, subscription should still be running", sub.Err())
+	}
+}
@@ -0,0 +1,239 @@
+package genesisdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// UnmarshalJSON decodes an event and keeps the raw JSON of its data, so
+// DecodeData can decode it into a concrete type without re-encoding Data.
+//
+// Data is an exported field that callers read directly, so it cannot be
+// filled in lazily and is decoded eagerly. Each event therefore
+// holds its data twice, generically in Data and as raw JSON, and is decoded
+// twice if DecodeData is used as well. The extra copy is the size of the
+// data; the gain is that DecodeData neither re-encodes Data nor loses
+// number precision and field order on the way.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type event Event
+	aux := struct {
+		*event
+		Data json.RawMessage `json:"data"`
+	}{event: (*event)(e)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	e.rawData = aux.Data
+	e.Data = nil
+	if len(aux.Data) > 0 {
+		if err := json.Unmarshal(aux.Data, &e.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RawData returns the data of the event as it was received from the API, or
+// nil if the event was not decoded from an API response.
+func (e Event) RawData() json.RawMessage {
+	return e.rawData
+}
+
+// DecodeData decodes the data of event into T. Events received from the API
+// are decoded straight from the received JSON; for other events Data is
+// converted through JSON.
+func DecodeData[T any](event Event) (T, error) {
+	var value T
+
+	raw := event.rawData
+	if raw == nil {
+		var err error
+		if raw, err = json.Marshal(event.Data); err != nil {
+			return value, fmt.Errorf("error encoding event data: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return value, fmt.Errorf("error decoding data of event %s: %w", event.ID, err)
+	}
+	return value, nil
+}
+
+// TypedEvent is an event whose data is decoded into T on first use.
+type TypedEvent[T any] struct {
+	Event
+
+	once  sync.Once
+	value T
+	err   error
+}
+
+// NewTypedEvent wraps event for typed access to its data.
+func NewTypedEvent[T any](event Event) *TypedEvent[T] {
+	return &TypedEvent[T]{Event: event}
+}
+
+// Decode returns the data of the event decoded into T. The data is decoded
+// once; later calls return the same result.
+func (e *TypedEvent[T]) Decode() (T, error) {
+	e.once.Do(func() {
+		e.value, e.err = DecodeData[T](e.Event)
+	})
+	return e.value, e.err
+}
+
+// TypedEvents wraps every event for typed access to its data.
+func TypedEvents[T any](events []Event) []*TypedEvent[T] {
+	typed := make([]*TypedEvent[T], len(events))
+	for i, event := range events {
+		typed[i] = NewTypedEvent[T](event)
+	}
+	return typed
+}
+
+// DecodeResult decodes the current result of it into T straight from the
+// received JSON line.
+func DecodeResult[T any](it *ResultIterator) (T, error) {
+	var value T
+	if err := json.Unmarshal([]byte(it.lines.line), &value); err != nil {
+		return value, fmt.Errorf("error decoding result: %w", err)
+	}
+	return value, nil
+}
+
+// QueryOption configures QueryAs and its variants.
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	params []Params
+	strict bool
+}
+
+// WithQueryParams binds params to the $name placeholders of the query, see
+// BindParams.
+func WithQueryParams(params Params) QueryOption {
+	return func(o *queryOptions) {
+		o.params = append(o.params, params)
+	}
+}
+
+// WithStrictDecoding fails on results with fields that T does not have.
+func WithStrictDecoding() QueryOption {
+	return func(o *queryOptions) {
+		o.strict = true
+	}
+}
+
+// ResultDecodeError is returned when a query result cannot be decoded into
+// the requested type.
+type ResultDecodeError struct {
+	// Line is the line of the result in the response, starting at 1.
+	Line int
+	Err  error
+}
+
+func (e *ResultDecodeError) Error() string {
+	return fmt.Sprintf("error decoding result on line %d: %v", e.Line, e.Err)
+}
+
+func (e *ResultDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TypedResultIterator decodes every query result straight from its JSON
+// line into T. It is used like ResultIterator.
+type TypedResultIterator[T any] struct {
+	lines  *lineIterator
+	strict bool
+	result T
+}
+
+// QueryAsIterator is like QueryAs but returns an iterator that decodes
+// results as they are read.
+func QueryAsIterator[T any](ctx context.Context, es *Genesisdb, query string, opts ...QueryOption) (*TypedResultIterator[T], error) {
+	options := &queryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	it, err := es.QIterator(ctx, query, options.params...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedResultIterator[T]{lines: it.lines, strict: options.strict}, nil
+}
+
+// Next advances to the next result and reports whether there is one. It
+// returns false at the end of the results or on error; check Err afterwards.
+func (it *TypedResultIterator[T]) Next() bool {
+	if !it.lines.next() {
+		return false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(it.lines.line))
+	if it.strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	var result T
+	if err := decoder.Decode(&result); err != nil {
+		it.lines.fail(&ResultDecodeError{Line: it.lines.number, Err: err})
+		return false
+	}
+
+	it.result = result
+	return true
+}
+
+// Result returns the result Next advanced to.
+func (it *TypedResultIterator[T]) Result() T {
+	return it.result
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *TypedResultIterator[T]) Err() error {
+	return it.lines.err
+}
+
+// Close releases the connection. It is safe to call Close more than once.
+func (it *TypedResultIterator[T]) Close() error {
+	return it.lines.close()
+}
+
+// QueryAs executes a GDBQL query and decodes every result into T, typically
+// a struct matching the MAP projection of the query:
+//
+//	type customer struct {
+//		ID        string `json:"id"`
+//		FirstName string `json:"firstName"`
+//	}
+//
+//	customers, err := genesisdb.QueryAs[customer](client, "STREAM e FROM events MAP { id: e.id, firstName: e.data.firstName }")
+func QueryAs[T any](es *Genesisdb, query string, opts ...QueryOption) ([]T, error) {
+	return QueryAsContext[T](context.Background(), es, query, opts...)
+}
+
+// QueryAsContext is like QueryAs but aborts the request and stops reading
+// results once ctx is done.
+func QueryAsContext[T any](ctx context.Context, es *Genesisdb, query string, opts ...QueryOption) ([]T, error) {
+	it, err := QueryAsIterator[T](ctx, es, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var results []T
+	for it.Next() {
+		results = append(results, it.Result())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
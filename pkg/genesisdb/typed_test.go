@@ -0,0 +1,178 @@
+package genesisdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type customerAdded struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+func TestDecodeData(t *testing.T) {
+	t.Run("From received JSON", func(t *testing.T) {
+		var event Event
+		if err := json.Unmarshal([]byte(`{"id":"1","subject":"/customer","type":"customer-added","data":{"firstName":"Bruce","lastName":"Wayne"}}`), &event); err != nil {
+			t.Fatalf("Unmarshal error = %v", err)
+		}
+
+		if string(event.RawData()) != `{"firstName":"Bruce","lastName":"Wayne"}` {
+			t.Errorf("RawData() = %s", event.RawData())
+		}
+		if data, ok := event.Data.(map[string]interface{}); !ok || data["firstName"] != "Bruce" {
+			t.Errorf("Data should still be decoded generically, got %v", event.Data)
+		}
+
+		customer, err := DecodeData[customerAdded](event)
+		if err != nil {
+			t.Fatalf("DecodeData() error = %v", err)
+		}
+		if customer.FirstName != "Bruce" || customer.LastName != "Wayne" {
+			t.Errorf("Unexpected data: %+v", customer)
+		}
+	})
+
+	t.Run("From event built in code", func(t *testing.T) {
+		event := Event{Data: map[string]interface{}{"firstName": "Alfred"}}
+
+		customer, err := DecodeData[customerAdded](event)
+		if err != nil {
+			t.Fatalf("DecodeData() error = %v", err)
+		}
+		if customer.FirstName != "Alfred" {
+			t.Errorf("Unexpected data: %+v", customer)
+		}
+	})
+
+	t.Run("Type mismatch", func(t *testing.T) {
+		var event Event
+		json.Unmarshal([]byte(`{"id":"1","subject":"/customer","type":"customer-added","data":{"firstName":42}}`), &event)
+
+		typed := NewTypedEvent[customerAdded](event)
+		if _, err := typed.Decode(); err == nil {
+			t.Error("Decode() should return error")
+		}
+		if _, err := typed.Decode(); err == nil {
+			t.Error("Decode() should return the same error again")
+		}
+	})
+}
+
+func TestTypedEvents_Mock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		switch r.URL.Path {
+		case "/api/v1/stream":
+			w.Write([]byte(`{"id":"1","subject":"/customer","type":"customer-added","data":{"firstName":"Bruce","lastName":"Wayne"}}` + "\n"))
+		case "/api/v1/q":
+			w.Write([]byte(`{"firstName":"Bruce","lastName":"Wayne"}` + "\n"))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"})
+
+	events, err := client.StreamEvents("/customer", nil)
+	if err != nil {
+		t.Fatalf("StreamEvents() error = %v", err)
+	}
+	typed := TypedEvents[customerAdded](events)
+	customer, err := typed[0].Decode()
+	if err != nil || customer.LastName != "Wayne" || typed[0].Subject != "/customer" {
+		t.Errorf("Decode() = %+v, %v", customer, err)
+	}
+
+	it, err := client.QIterator(context.Background(), "FROM e IN events")
+	if err != nil {
+		t.Fatalf("QIterator() error = %v", err)
+	}
+	defer it.Close()
+	if !it.Next() {
+		t.Fatalf("Expected a result, got error %v", it.Err())
+	}
+	result, err := DecodeResult[customerAdded](it)
+	if err != nil || result.FirstName != "Bruce" {
+		t.Errorf("DecodeResult() = %+v, %v", result, err)
+	}
+}
+
+type customerProjection struct {
+	ID        string `json:"id"`
+	FirstName string `json:"firstName"`
+}
+
+func TestQueryAs_Mock(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		query = body["query"]
+
+		w.WriteHeader(200)
+		w.Write([]byte(`{"id":"1","firstName":"Bruce"}` + "\n\n" +
+			`{"id":"2","firstName":"Alfred","lastName":"Pennyworth"}` + "\n" +
+			`{"id":"3","firstName":42}` + "\n"))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"})
+
+	t.Run("Decode error with line", func(t *testing.T) {
+		_, err := QueryAs[customerProjection](client, "STREAM e FROM events WHERE e.type == $type MAP { id: e.id, firstName: e.data.firstName }",
+			WithQueryParams(Params{"type": "customer-added"}))
+
+		var decodeErr *ResultDecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("QueryAs() error = %v, want *ResultDecodeError", err)
+		}
+		if decodeErr.Line != 4 {
+			t.Errorf("Line = %d, want 4", decodeErr.Line)
+		}
+		if query != "STREAM e FROM events WHERE e.type == 'customer-added' MAP { id: e.id, firstName: e.data.firstName }" {
+			t.Errorf("Unexpected query: %s", query)
+		}
+	})
+
+	t.Run("Iterator", func(t *testing.T) {
+		it, err := QueryAsIterator[customerProjection](context.Background(), client, "STREAM e FROM events")
+		if err != nil {
+			t.Fatalf("QueryAsIterator() error = %v", err)
+		}
+		defer it.Close()
+
+		var names []string
+		for it.Next() {
+			names = append(names, it.Result().FirstName)
+		}
+		if len(names) != 2 || names[0] != "Bruce" || names[1] != "Alfred" {
+			t.Errorf("Unexpected results: %v", names)
+		}
+		if it.Err() == nil {
+			t.Error("Expected error for the third result")
+		}
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		_, err := QueryAs[customerProjection](client, "STREAM e FROM events", WithStrictDecoding())
+
+		var decodeErr *ResultDecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Line != 3 {
+			t.Fatalf("QueryAs() error = %v, want *ResultDecodeError on line 3", err)
+		}
+	})
+
+	t.Run("Map results", func(t *testing.T) {
+		results, err := QueryAs[map[string]interface{}](client, "STREAM e FROM events")
+		if err != nil {
+			t.Fatalf("QueryAs() error = %v", err)
+		}
+		if len(results) != 3 || results[1]["lastName"] != "Pennyworth" {
+			t.Errorf("Unexpected results: %v", results)
+		}
+	})
+}
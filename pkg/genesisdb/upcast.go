@@ -0,0 +1,94 @@
+package genesisdb
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Upcaster transforms an event of an older schema version into the events
+// that replace it: usually one event with migrated data, possibly with a new
+// type, but an event may also be split into several or dropped. The returned
+// events must carry their new version, or the chain would apply the same
+// upcaster again.
+type Upcaster func(event Event) ([]Event, error)
+
+// maxUpcastSteps bounds the upcasters applied to a single event so that a
+// misconfigured chain cannot loop forever.
+const maxUpcastSteps = 100
+
+// UpcasterChain holds the upcasters for every event type and version. A
+// client configured with WithUpcasters passes every streamed and observed
+// event through the chain before it is delivered or decoded by a Registry.
+type UpcasterChain struct {
+	mu        sync.RWMutex
+	upcasters map[registryKey]Upcaster
+	version   VersionFunc
+}
+
+// NewUpcasterChain creates an empty chain. Versions are read from the
+// "schemaVersion" field of the event data; use SetVersionFunc to change that.
+func NewUpcasterChain() *UpcasterChain {
+	return &UpcasterChain{
+		upcasters: make(map[registryKey]Upcaster),
+		version:   DataVersion("schemaVersion"),
+	}
+}
+
+// SetVersionFunc changes how the chain determines event versions.
+func (c *UpcasterChain) SetVersionFunc(fn VersionFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = fn
+}
+
+// Register adds the upcaster for events of eventType with the given version.
+func (c *UpcasterChain) Register(eventType string, version int, upcaster Upcaster) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.upcasters[registryKey{eventType, version}] = upcaster
+}
+
+// Upcast applies upcasters to event until none matches any of the resulting
+// events, and returns those events in order.
+func (c *UpcasterChain) Upcast(event Event) ([]Event, error) {
+	return c.upcast(event, 0)
+}
+
+func (c *UpcasterChain) upcast(event Event, steps int) ([]Event, error) {
+	c.mu.RLock()
+	upcaster, ok := c.upcasters[registryKey{event.Type, c.version(event)}]
+	c.mu.RUnlock()
+	if !ok {
+		return []Event{event}, nil
+	}
+
+	if steps >= maxUpcastSteps {
+		return nil, fmt.Errorf("upcasting event %s: more than %d steps", event.ID, maxUpcastSteps)
+	}
+
+	upcasted, err := upcaster(event)
+	if err != nil {
+		return nil, fmt.Errorf("upcasting event %s of type %s: %w", event.ID, event.Type, err)
+	}
+
+	var result []Event
+	for _, next := range upcasted {
+		// The received JSON no longer matches the data
+		next.rawData = nil
+
+		events, err := c.upcast(next, steps+1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, events...)
+	}
+	return result, nil
+}
+
+// WithUpcasters passes streamed and observed events through chain before
+// they are delivered.
+func WithUpcasters(chain *UpcasterChain) Option {
+	return func(es *Genesisdb) {
+		es.upcasters = chain
+	}
+}
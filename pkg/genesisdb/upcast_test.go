@@ -0,0 +1,163 @@
+package genesisdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestUpcasterChain upcasts version 1 of "customer-added" by joining the
+// name fields, renames "customer-registered" to "customer-added", and splits
+// "customer-moved" into an address change and a phone change.
+func newTestUpcasterChain() *UpcasterChain {
+	chain := NewUpcasterChain()
+	chain.Register("customer-registered", 0, func(event Event) ([]Event, error) {
+		event.Type = "customer-added"
+		return []Event{event}, nil
+	})
+	chain.Register("customer-added", 0, func(event Event) ([]Event, error) {
+		data := event.Data.(map[string]interface{})
+		event.Data = map[string]interface{}{
+			"schemaVersion": 2,
+			"name":          data["firstName"].(string) + " " + data["lastName"].(string),
+		}
+		return []Event{event}, nil
+	})
+	chain.Register("customer-moved", 0, func(event Event) ([]Event, error) {
+		data := event.Data.(map[string]interface{})
+		address, phone := event, event
+		address.ID, address.Type, address.Data = event.ID+"-address", "customer-address-changed", map[string]interface{}{"city": data["city"]}
+		phone.ID, phone.Type, phone.Data = event.ID+"-phone", "customer-phone-changed", map[string]interface{}{"phone": data["phone"]}
+		return []Event{address, phone}, nil
+	})
+	return chain
+}
+
+func TestUpcasterChain_Upcast(t *testing.T) {
+	chain := newTestUpcasterChain()
+
+	events, err := chain.Upcast(Event{ID: "1", Type: "customer-registered", Data: map[string]interface{}{"firstName": "Bruce", "lastName": "Wayne"}})
+	if err != nil {
+		t.Fatalf("Upcast() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "customer-added" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+	if data := events[0].Data.(map[string]interface{}); data["name"] != "Bruce Wayne" {
+		t.Errorf("Rename should be followed by the data upcaster, got %v", data)
+	}
+
+	events, _ = chain.Upcast(Event{ID: "2", Type: "customer-added", Data: map[string]interface{}{"schemaVersion": 2.0, "name": "Alfred"}})
+	if len(events) != 1 || events[0].Data.(map[string]interface{})["name"] != "Alfred" {
+		t.Errorf("Current events should pass unchanged, got %+v", events)
+	}
+
+	t.Run("Loop is detected", func(t *testing.T) {
+		loop := NewUpcasterChain()
+		loop.Register("ping", 0, func(event Event) ([]Event, error) {
+			return []Event{event}, nil
+		})
+		if _, err := loop.Upcast(Event{Type: "ping"}); err == nil {
+			t.Error("Upcast() should fail for a looping chain")
+		}
+	})
+
+	t.Run("Upcaster error", func(t *testing.T) {
+		failing := NewUpcasterChain()
+		failing.Register("broken", 0, func(event Event) ([]Event, error) {
+			return nil, errors.New("cannot migrate")
+		})
+		if _, err := failing.Upcast(Event{Type: "broken"}); err == nil || !strings.Contains(err.Error(), "cannot migrate") {
+			t.Errorf("Upcast() error = %v", err)
+		}
+	})
+}
+
+func TestUpcasterChain_Mock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte(strings.Join([]string{
+			`{"id":"1","subject":"/customer/1","type":"customer-registered","data":{"firstName":"Bruce","lastName":"Wayne"}}`,
+			`{"id":"2","subject":"/customer/1","type":"customer-moved","data":{"city":"Gotham","phone":"555"}}`,
+			`{"id":"3","subject":"/customer/2","type":"customer-added","data":{"schemaVersion":2,"name":"Alfred Pennyworth"}}`,
+		}, "\n") + "\n"))
+	}))
+	defer server.Close()
+
+	registry := NewRegistry(UnknownTypeKeepRaw)
+	RegisterVersion[customerAddedV2](registry, "customer-added", 2)
+
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"},
+		WithUpcasters(newTestUpcasterChain()),
+		WithRegistry(registry),
+	)
+
+	check := func(t *testing.T, events []Event) {
+		t.Helper()
+		var types []string
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+		want := "customer-added,customer-address-changed,customer-phone-changed,customer-added"
+		if strings.Join(types, ",") != want {
+			t.Fatalf("Event types = %v, want %s", types, want)
+		}
+		// The registry must decode the upcasted data, not the received JSON
+		if data, ok := events[0].Data.(customerAddedV2); !ok || data.Name != "Bruce Wayne" {
+			t.Errorf("Unexpected data of upcasted event: %#v", events[0].Data)
+		}
+		if data, ok := events[3].Data.(customerAddedV2); !ok || data.Name != "Alfred Pennyworth" {
+			t.Errorf("Unexpected data of current event: %#v", events[3].Data)
+		}
+		// Only the last part of the split event resumes after it
+		var resumeIDs []string
+		for _, event := range events {
+			resumeIDs = append(resumeIDs, event.ResumeID())
+		}
+		if strings.Join(resumeIDs, ",") != "1,,2,3" {
+			t.Errorf("ResumeIDs = %q, want [1 \"\" 2 3]", resumeIDs)
+		}
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		events, err := client.StreamEvents("/customer", nil)
+		if err != nil {
+			t.Fatalf("StreamEvents() error = %v", err)
+		}
+		check(t, events)
+	})
+
+	t.Run("Iterator", func(t *testing.T) {
+		it, err := client.StreamEventsIterator(context.Background(), "/customer", nil)
+		if err != nil {
+			t.Fatalf("StreamEventsIterator() error = %v", err)
+		}
+		defer it.Close()
+
+		var events []Event
+		for it.Next() {
+			events = append(events, it.Event())
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("Err() = %v", err)
+		}
+		check(t, events)
+	})
+
+	t.Run("Observe", func(t *testing.T) {
+		sub := client.Observe(context.Background(), "/customer", nil)
+		defer sub.Close()
+
+		var events []Event
+		for event := range sub.Events() {
+			events = append(events, event)
+		}
+		check(t, events)
+		if sub.LastEventID() != "3" {
+			t.Errorf("LastEventID() = %s, want ID of the received event", sub.LastEventID())
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package genesisdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// EventValidator checks events before they are committed. A non-nil error
+// aborts the commit before any request is sent.
+type EventValidator interface {
+	Validate(events []Event) error
+}
+
+// WithValidator validates the events of every commit with validator.
+func WithValidator(validator EventValidator) Option {
+	return func(es *Genesisdb) {
+		es.validator = validator
+	}
+}
+
+// ValidationFailure describes one way in which an event did not match its
+// schema.
+type ValidationFailure struct {
+	// Index is the position of the event in the committed slice.
+	Index     int
+	EventType string
+	// Pointer is the JSON pointer to the offending value within the event
+	// data, empty for the data itself.
+	Pointer string
+	Message string
+}
+
+// ValidationError is returned by SchemaValidator when events do not match
+// their schemas. It lists every failure of every event.
+type ValidationError struct {
+	Failures []ValidationFailure
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("event validation failed:")
+	for _, f := range e.Failures {
+		pointer := f.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		fmt.Fprintf(&b, "\n  event %d (%s) at %s: %s", f.Index, f.EventType, pointer, f.Message)
+	}
+	return b.String()
+}
+
+// SchemaValidator validates the data of events against the JSON Schema
+// registered for their type. Events of types without a schema pass unless
+// RequireSchema is set.
+type SchemaValidator struct {
+	// RequireSchema rejects events whose type has no schema.
+	RequireSchema bool
+
+	mu      sync.RWMutex
+	schemas map[string]*jsonschema.Schema
+}
+
+// NewSchemaValidator creates a validator without schemas.
+func NewSchemaValidator() *SchemaValidator {
+	return &SchemaValidator{schemas: make(map[string]*jsonschema.Schema)}
+}
+
+// AddSchema compiles schema and uses it for events of eventType.
+func (v *SchemaValidator) AddSchema(eventType string, schema []byte) error {
+	compiler := jsonschema.NewCompiler()
+	url := "schema:///" + eventType + ".json"
+	if err := compiler.AddResource(url, bytes.NewReader(schema)); err != nil {
+		return fmt.Errorf("error loading schema for %s: %w", eventType, err)
+	}
+	return v.compile(compiler, eventType, url)
+}
+
+// LoadFS loads every .json file in dir of fsys as the schema for the event
+// type named like the file without its extension, e.g.
+// "io.genesisdb.app.customer-added.json". Schemas may reference each other
+// with relative $refs.
+func (v *SchemaValidator) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("error reading schema directory: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	types := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		name := path.Join(dir, entry.Name())
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("error reading schema %s: %w", name, err)
+		}
+
+		url := "schema:///" + name
+		if err := compiler.AddResource(url, bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("error loading schema %s: %w", name, err)
+		}
+		types[strings.TrimSuffix(entry.Name(), ".json")] = url
+	}
+
+	for eventType, url := range types {
+		if err := v.compile(compiler, eventType, url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadDir is like LoadFS for a directory on disk.
+func (v *SchemaValidator) LoadDir(dir string) error {
+	return v.LoadFS(os.DirFS(dir), ".")
+}
+
+func (v *SchemaValidator) compile(compiler *jsonschema.Compiler, eventType, url string) error {
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		return fmt.Errorf("error compiling schema for %s: %w", eventType, err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.schemas[eventType] = schema
+	return nil
+}
+
+// Validate checks the data of every event and returns a *ValidationError
+// listing all failures.
+func (v *SchemaValidator) Validate(events []Event) error {
+	var failures []ValidationFailure
+	for i, event := range events {
+		v.mu.RLock()
+		schema, ok := v.schemas[event.Type]
+		v.mu.RUnlock()
+
+		if !ok {
+			if v.RequireSchema {
+				failures = append(failures, ValidationFailure{Index: i, EventType: event.Type, Message: "no schema for event type"})
+			}
+			continue
+		}
+
+		data, err := normalizeData(event.Data)
+		if err != nil {
+			failures = append(failures, ValidationFailure{Index: i, EventType: event.Type, Message: err.Error()})
+			continue
+		}
+
+		if err := schema.Validate(data); err != nil {
+			ve, ok := err.(*jsonschema.ValidationError)
+			if !ok {
+				failures = append(failures, ValidationFailure{Index: i, EventType: event.Type, Message: err.Error()})
+				continue
+			}
+			for _, leaf := range leafErrors(ve) {
+				failures = append(failures, ValidationFailure{
+					Index:     i,
+					EventType: event.Type,
+					Pointer:   leaf.InstanceLocation,
+					Message:   leaf.Message,
+				})
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return &ValidationError{Failures: failures}
+	}
+	return nil
+}
+
+// normalizeData converts data into the generic form produced by
+// encoding/json, which is what the schema validator expects.
+func normalizeData(data interface{}) (interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding event data: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var normalized interface{}
+	if err := decoder.Decode(&normalized); err != nil {
+		return nil, fmt.Errorf("error encoding event data: %w", err)
+	}
+	return normalized, nil
+}
+
+// leafErrors returns the innermost causes of ve, which point at the actual
+// offending values.
+func leafErrors(ve *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(ve.Causes) == 0 {
+		return []*jsonschema.ValidationError{ve}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range ve.Causes {
+		leaves = append(leaves, leafErrors(cause)...)
+	}
+	return leaves
+}
@@ -0,0 +1,146 @@
+package genesisdb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+const customerAddedSchema = `{
+	"type": "object",
+	"properties": {
+		"firstName": {"type": "string", "minLength": 1},
+		"address": {"$ref": "address.json"}
+	},
+	"required": ["firstName"]
+}`
+
+const addressSchema = `{
+	"type": "object",
+	"properties": {"zip": {"type": "string", "pattern": "^[0-9]{5}$"}},
+	"required": ["zip"]
+}`
+
+func TestSchemaValidator_Validate(t *testing.T) {
+	validator := NewSchemaValidator()
+	err := validator.LoadFS(fstest.MapFS{
+		"schemas/customer-added.json": {Data: []byte(customerAddedSchema)},
+		"schemas/address.json":        {Data: []byte(addressSchema)},
+		"schemas/README.md":           {Data: []byte("not a schema")},
+	}, "schemas")
+	if err != nil {
+		t.Fatalf("LoadFS() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		events   []Event
+		pointers []string
+	}{
+		{
+			name: "Valid",
+			events: []Event{
+				{Type: "customer-added", Data: map[string]interface{}{"firstName": "Bruce", "address": map[string]interface{}{"zip": "12345"}}},
+				{Type: "article-added", Data: map[string]interface{}{"sku": 1}},
+			},
+		},
+		{
+			name:     "Struct data",
+			events:   []Event{{Type: "customer-added", Data: customerAdded{FirstName: ""}}},
+			pointers: []string{"/firstName"},
+		},
+		{
+			name: "Several failures",
+			events: []Event{
+				{Type: "customer-added", Data: map[string]interface{}{"firstName": "Bruce"}},
+				{Type: "customer-added", Data: map[string]interface{}{"address": map[string]interface{}{"zip": "abc"}}},
+			},
+			pointers: []string{"", "/address/zip"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(tt.events)
+			if tt.pointers == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if len(validationErr.Failures) != len(tt.pointers) {
+				t.Fatalf("Expected %d failures, got %+v", len(tt.pointers), validationErr.Failures)
+			}
+			for i, failure := range validationErr.Failures {
+				if failure.Pointer != tt.pointers[i] {
+					t.Errorf("Failure %d pointer = %q, want %q", i, failure.Pointer, tt.pointers[i])
+				}
+				if failure.EventType != "customer-added" || failure.Message == "" {
+					t.Errorf("Unexpected failure: %+v", failure)
+				}
+			}
+		})
+	}
+
+	t.Run("Require schema", func(t *testing.T) {
+		strict := NewSchemaValidator()
+		strict.RequireSchema = true
+		if err := strict.AddSchema("customer-added", []byte(`{"type": "object"}`)); err != nil {
+			t.Fatalf("AddSchema() error = %v", err)
+		}
+
+		err := strict.Validate([]Event{
+			{Type: "customer-added", Data: map[string]interface{}{}},
+			{Type: "article-added", Data: map[string]interface{}{}},
+		})
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || len(validationErr.Failures) != 1 || validationErr.Failures[0].Index != 1 {
+			t.Errorf("Validate() error = %v", err)
+		}
+	})
+
+	t.Run("Invalid schema", func(t *testing.T) {
+		if err := NewSchemaValidator().AddSchema("customer-added", []byte(`{"type": 1}`)); err == nil {
+			t.Error("Expected error for invalid schema")
+		}
+	})
+}
+
+func TestSchemaValidator_Mock(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	validator := NewSchemaValidator()
+	if err := validator.AddSchema("customer-added", []byte(`{"required": ["firstName"]}`)); err != nil {
+		t.Fatalf("AddSchema() error = %v", err)
+	}
+	client, _ := NewClient(&Config{APIURL: server.URL, APIVersion: "v1", AuthToken: "test-token"}, WithValidator(validator))
+
+	err := client.CommitEvents([]Event{{Source: "io.genesisdb.app", Subject: "/customer", Type: "customer-added", Data: map[string]interface{}{}}})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("CommitEvents() error = %v, want *ValidationError", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request for invalid events, got %d", requests)
+	}
+
+	err = client.CommitEvents([]Event{{Source: "io.genesisdb.app", Subject: "/customer", Type: "customer-added", Data: map[string]interface{}{"firstName": "Bruce"}}})
+	if err != nil {
+		t.Fatalf("CommitEvents() error = %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 request, got %d", requests)
+	}
+}
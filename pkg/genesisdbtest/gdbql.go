@@ -0,0 +1,1032 @@
+package genesisdbtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errQuery marks errors in queries, which the server answers with 400 Bad
+// Request.
+var errQuery = errors.New("invalid query")
+
+func queryError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errQuery, fmt.Sprintf(format, args...))
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenParam
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// keyword reports whether t is the keyword kw, in any case.
+func (t token) keyword(kw string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, kw)
+}
+
+func (t token) symbol(s string) bool {
+	return t.kind == tokenSymbol && t.text == s
+}
+
+// symbols lists the operators and punctuation, longest first.
+var symbols = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "=", "!", "+", "-", "*", "/", "%", ".", ",", "(", ")", "[", "]", "{", "}", ":"}
+
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			start := i
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] != '\\' || i+1 == len(runes) {
+					b.WriteRune(runes[i])
+					continue
+				}
+				i++
+				switch runes[i] {
+				case 'n':
+					b.WriteRune('\n')
+				case 'r':
+					b.WriteRune('\r')
+				case 't':
+					b.WriteRune('\t')
+				case 'u':
+					if i+4 >= len(runes) {
+						return nil, queryError("invalid escape at %d", i)
+					}
+					code, err := strconv.ParseUint(string(runes[i+1:i+5]), 16, 32)
+					if err != nil {
+						return nil, queryError("invalid escape at %d", i)
+					}
+					b.WriteRune(rune(code))
+					i += 4
+				default:
+					b.WriteRune(runes[i])
+				}
+			}
+			if i == len(runes) {
+				return nil, queryError("unterminated string at %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start})
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			num, err := strconv.ParseFloat(string(runes[start:i]), 64)
+			if err != nil {
+				return nil, queryError("invalid number %q", string(runes[start:i]))
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), num: num, pos: start})
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			kind := tokenIdent
+			if r == '$' {
+				kind = tokenParam
+			}
+			tokens = append(tokens, token{kind: kind, text: string(runes[start:i]), pos: start})
+		default:
+			matched := false
+			for _, s := range symbols {
+				if strings.HasPrefix(string(runes[i:]), s) {
+					tokens = append(tokens, token{kind: tokenSymbol, text: s, pos: i})
+					i += len([]rune(s))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, queryError("unexpected %q at %d", r, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// scope is what an expression is evaluated against: an event, as decoded
+// from its JSON form, and the group of events it belongs to for aggregate
+// functions.
+type scope struct {
+	row   map[string]interface{}
+	group []map[string]interface{}
+}
+
+type expr func(s *scope) (interface{}, error)
+
+type orderKey struct {
+	expr expr
+	desc bool
+}
+
+// query is a parsed GDBQL query:
+//
+//	STREAM e FROM events [WHERE cond] [GROUP BY expr [HAVING cond]]
+//	    [ORDER BY expr [ASC|DESC], ...] [LIMIT n] [MAP expr]
+//
+// The older form FROM e IN events ... TOP n PROJECT INTO expr is accepted
+// as well.
+type query struct {
+	where      expr
+	groupBy    expr
+	having     expr
+	orderBy    []orderKey
+	limit      int
+	mapping    expr
+	aggregates bool
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	variable string
+	// aggregate is set when an aggregate function is parsed
+	aggregate bool
+}
+
+func parseQuery(text string) (*query, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q := &query{limit: -1}
+
+	switch {
+	case p.accept("STREAM"):
+		if p.variable, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("FROM"); err != nil {
+			return nil, err
+		}
+	case p.accept("FROM"):
+		if p.variable, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("IN"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.unexpected()
+	}
+	if err := p.expect("events"); err != nil {
+		return nil, err
+	}
+
+	if p.accept("WHERE") {
+		if q.where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("GROUP") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		if q.groupBy, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if p.accept("HAVING") {
+			if q.having, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			key := orderKey{}
+			if key.expr, err = p.expr(); err != nil {
+				return nil, err
+			}
+			if p.accept("DESC") {
+				key.desc = true
+			} else {
+				p.accept("ASC")
+			}
+			q.orderBy = append(q.orderBy, key)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+	if p.accept("LIMIT") || p.accept("TOP") {
+		t := p.next()
+		if t.kind != tokenNumber || t.num < 0 || t.num != math.Trunc(t.num) {
+			return nil, queryError("invalid limit at %d", t.pos)
+		}
+		q.limit = int(t.num)
+	}
+	if p.accept("MAP") || (p.accept("PROJECT") && p.accept("INTO")) {
+		p.aggregate = false
+		if q.mapping, err = p.expr(); err != nil {
+			return nil, err
+		}
+		q.aggregates = p.aggregate
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return q, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(keyword string) bool {
+	if p.peek().keyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if p.peek().symbol(symbol) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(keyword string) error {
+	if !p.accept(keyword) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return "", queryError("expected identifier at %d", t.pos)
+	}
+	return t.text, nil
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	switch t.kind {
+	case tokenEOF:
+		return queryError("unexpected end of query")
+	case tokenParam:
+		return queryError("unbound parameter %s at %d", t.text, t.pos)
+	}
+	return queryError("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) expr() (expr, error) {
+	return p.or()
+}
+
+func (p *parser) or() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") || p.acceptSymbol("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = logical(left, right, true)
+	}
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") || p.acceptSymbol("&&") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = logical(left, right, false)
+	}
+	return left, nil
+}
+
+// logical combines two conditions with OR, or with AND, short-circuiting
+// the right one.
+func logical(left, right expr, or bool) expr {
+	return func(s *scope) (interface{}, error) {
+		l, err := left(s)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(l) == or {
+			return or, nil
+		}
+		r, err := right(s)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(r), nil
+	}
+}
+
+func (p *parser) not() (expr, error) {
+	if p.accept("NOT") || p.acceptSymbol("!") {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(s *scope) (interface{}, error) {
+			v, err := operand(s)
+			if err != nil {
+				return nil, err
+			}
+			return !truthy(v), nil
+		}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.symbol("==") || t.symbol("=") || t.symbol("!=") || t.symbol("<") || t.symbol("<=") || t.symbol(">") || t.symbol(">="):
+		p.next()
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return binary(left, right, func(l, r interface{}) (interface{}, error) {
+			return compareOp(t.text, l, r), nil
+		}), nil
+	case t.keyword("IN") || (t.keyword("NOT") && p.tokens[p.pos+1].keyword("IN")):
+		negate := p.accept("NOT")
+		p.next()
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return binary(left, right, func(l, r interface{}) (interface{}, error) {
+			list, ok := r.([]interface{})
+			if !ok {
+				return nil, queryError("IN needs a list")
+			}
+			for _, item := range list {
+				if equal(l, item) {
+					return !negate, nil
+				}
+			}
+			return negate, nil
+		}), nil
+	case t.keyword("UNDER"):
+		p.next()
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return binary(left, right, func(l, r interface{}) (interface{}, error) {
+			subject, ok1 := l.(string)
+			parent, ok2 := r.(string)
+			return ok1 && ok2 && under(subject, parent), nil
+		}), nil
+	case t.keyword("BETWEEN"):
+		p.next()
+		low, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return func(s *scope) (interface{}, error) {
+			values, err := evalAll(s, left, low, high)
+			if err != nil {
+				return nil, err
+			}
+			return compareOp(">=", values[0], values[1]) && compareOp("<=", values[0], values[2]), nil
+		}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) additive() (expr, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.symbol("+") || t.symbol("-"); t = p.peek() {
+		p.next()
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = binary(left, right, arithmetic(t.text))
+	}
+	return left, nil
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.symbol("*") || t.symbol("/") || t.symbol("%"); t = p.peek() {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binary(left, right, arithmetic(t.text))
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.acceptSymbol("-") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return binary(constant(0.0), operand, arithmetic("-")), nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (expr, error) {
+	operand, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.acceptSymbol("."):
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			operand = field(operand, constant(name))
+		case p.acceptSymbol("["):
+			index, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+			operand = field(operand, index)
+		default:
+			return operand, nil
+		}
+	}
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenNumber:
+		return constant(t.num), nil
+	case t.kind == tokenString:
+		return constant(t.text), nil
+	case t.keyword("true"):
+		return constant(true), nil
+	case t.keyword("false"):
+		return constant(false), nil
+	case t.keyword("null"):
+		return constant(nil), nil
+	case t.symbol("("):
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expectSymbol(")")
+	case t.symbol("["):
+		return p.list()
+	case t.symbol("{"):
+		return p.object()
+	case t.kind == tokenIdent && p.peek().symbol("("):
+		return p.function(t)
+	case t.kind == tokenIdent && t.text == p.variable:
+		return func(s *scope) (interface{}, error) { return s.row, nil }, nil
+	case t.kind == tokenIdent:
+		return nil, queryError("unknown identifier %s at %d", t.text, t.pos)
+	}
+	if t.kind != tokenEOF {
+		p.pos--
+	}
+	return nil, p.unexpected()
+}
+
+func (p *parser) list() (expr, error) {
+	var items []expr
+	for !p.acceptSymbol("]") {
+		if len(items) > 0 {
+			if err := p.expectSymbol(","); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return func(s *scope) (interface{}, error) {
+		values, err := evalAll(s, items...)
+		if values == nil && err == nil {
+			values = []interface{}{}
+		}
+		return values, err
+	}, nil
+}
+
+func (p *parser) object() (expr, error) {
+	var keys []string
+	var values []expr
+	for !p.acceptSymbol("}") {
+		if len(keys) > 0 {
+			if err := p.expectSymbol(","); err != nil {
+				return nil, err
+			}
+		}
+		key := p.next()
+		if key.kind != tokenIdent && key.kind != tokenString {
+			return nil, queryError("expected key at %d", key.pos)
+		}
+		if err := p.expectSymbol(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.text)
+		values = append(values, value)
+	}
+	return func(s *scope) (interface{}, error) {
+		evaluated, err := evalAll(s, values...)
+		if err != nil {
+			return nil, err
+		}
+		object := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			object[key] = evaluated[i]
+		}
+		return object, nil
+	}, nil
+}
+
+// function parses a call of an aggregate function, which is evaluated over
+// the group of the current event.
+func (p *parser) function(name token) (expr, error) {
+	p.next()
+	var arg expr
+	if !p.acceptSymbol(")") {
+		var err error
+		if arg, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	fn := strings.ToUpper(name.text)
+	if fn != "COUNT" && arg == nil {
+		return nil, queryError("%s needs an argument", fn)
+	}
+	switch fn {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+	default:
+		return nil, queryError("unknown function %s at %d", name.text, name.pos)
+	}
+	p.aggregate = true
+
+	return func(s *scope) (interface{}, error) {
+		group := s.group
+		if group == nil {
+			group = []map[string]interface{}{s.row}
+		}
+		var values []interface{}
+		for _, row := range group {
+			if arg == nil {
+				values = append(values, true)
+				continue
+			}
+			v, err := arg(&scope{row: row})
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				values = append(values, v)
+			}
+		}
+		return aggregate(fn, values)
+	}, nil
+}
+
+func aggregate(fn string, values []interface{}) (interface{}, error) {
+	if fn == "COUNT" {
+		return float64(len(values)), nil
+	}
+	if len(values) == 0 {
+		if fn == "SUM" {
+			return 0.0, nil
+		}
+		return nil, nil
+	}
+	if fn == "MIN" || fn == "MAX" {
+		result := values[0]
+		for _, v := range values[1:] {
+			if c, ok := compare(v, result); ok && (c < 0) == (fn == "MIN") && c != 0 {
+				result = v
+			}
+		}
+		return result, nil
+	}
+	sum := 0.0
+	for _, v := range values {
+		n, ok := v.(float64)
+		if !ok {
+			return nil, queryError("%s needs numbers", fn)
+		}
+		sum += n
+	}
+	if fn == "AVG" {
+		return sum / float64(len(values)), nil
+	}
+	return sum, nil
+}
+
+func constant(v interface{}) expr {
+	return func(*scope) (interface{}, error) { return v, nil }
+}
+
+func binary(left, right expr, op func(l, r interface{}) (interface{}, error)) expr {
+	return func(s *scope) (interface{}, error) {
+		values, err := evalAll(s, left, right)
+		if err != nil {
+			return nil, err
+		}
+		return op(values[0], values[1])
+	}
+}
+
+func evalAll(s *scope, exprs ...expr) ([]interface{}, error) {
+	var values []interface{}
+	for _, e := range exprs {
+		v, err := e(s)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// field accesses a member of an object or an element of a list. Missing
+// members are null.
+func field(operand, key expr) expr {
+	return binary(operand, key, func(container, key interface{}) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if name, ok := key.(string); ok {
+				return c[name], nil
+			}
+		case []interface{}:
+			if i, ok := key.(float64); ok && i >= 0 && int(i) < len(c) {
+				return c[int(i)], nil
+			}
+		}
+		return nil, nil
+	})
+}
+
+func arithmetic(op string) func(l, r interface{}) (interface{}, error) {
+	return func(l, r interface{}) (interface{}, error) {
+		if op == "+" {
+			ls, lok := l.(string)
+			rs, rok := r.(string)
+			if lok || rok {
+				if !lok {
+					ls = format(l)
+				}
+				if !rok {
+					rs = format(r)
+				}
+				return ls + rs, nil
+			}
+		}
+		a, aok := l.(float64)
+		b, bok := r.(float64)
+		if !aok || !bok {
+			return nil, nil
+		}
+		switch op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/":
+			if b == 0 {
+				return nil, nil
+			}
+			return a / b, nil
+		default:
+			if b == 0 {
+				return nil, nil
+			}
+			return math.Mod(a, b), nil
+		}
+	}
+}
+
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func truthy(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+func equal(a, b interface{}) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compareOp(op string, l, r interface{}) bool {
+	switch op {
+	case "==", "=":
+		return equal(l, r)
+	case "!=":
+		return !equal(l, r)
+	}
+	c, ok := compare(l, r)
+	if !ok {
+		return false
+	}
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// compare orders numbers, strings and booleans among their own kind.
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0, true
+			case !a:
+				return -1, true
+			}
+			return 1, true
+		}
+	case nil:
+		if b == nil {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// sortRank orders values of different kinds: null, booleans, numbers,
+// strings, then everything else.
+func sortRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
+
+// under reports whether subject is parent or one of its descendants.
+func under(subject, parent string) bool {
+	if parent == "/" || subject == parent {
+		return true
+	}
+	return strings.HasPrefix(subject, strings.TrimSuffix(parent, "/")+"/")
+}
+
+// run evaluates the query over rows, the events as decoded from their JSON
+// form in the order they were committed.
+func (q *query) run(rows []map[string]interface{}) ([]interface{}, error) {
+	var selected []map[string]interface{}
+	for _, row := range rows {
+		if q.where != nil {
+			v, err := q.where(&scope{row: row})
+			if err != nil {
+				return nil, err
+			}
+			if !truthy(v) {
+				continue
+			}
+		}
+		selected = append(selected, row)
+	}
+
+	var groups []*scope
+	switch {
+	case q.groupBy != nil:
+		index := make(map[string]*scope)
+		for _, row := range selected {
+			key, err := q.groupBy(&scope{row: row})
+			if err != nil {
+				return nil, err
+			}
+			id := fmt.Sprintf("%T:%v", key, key)
+			group, ok := index[id]
+			if !ok {
+				group = &scope{row: row}
+				index[id] = group
+				groups = append(groups, group)
+			}
+			group.group = append(group.group, row)
+		}
+		if err := q.sort(groups); err != nil {
+			return nil, err
+		}
+	default:
+		for _, row := range selected {
+			groups = append(groups, &scope{row: row})
+		}
+		if err := q.sort(groups); err != nil {
+			return nil, err
+		}
+		if q.limit >= 0 && len(groups) > q.limit {
+			groups = groups[:q.limit]
+		}
+		if q.aggregates || q.having != nil {
+			all := &scope{group: []map[string]interface{}{}}
+			for _, group := range groups {
+				all.group = append(all.group, group.row)
+			}
+			if len(all.group) > 0 {
+				all.row = all.group[0]
+			}
+			groups = []*scope{all}
+		}
+	}
+
+	var results []interface{}
+	for _, group := range groups {
+		if q.having != nil {
+			v, err := q.having(group)
+			if err != nil {
+				return nil, err
+			}
+			if !truthy(v) {
+				continue
+			}
+		}
+		if q.groupBy != nil && q.limit >= 0 && len(results) == q.limit {
+			break
+		}
+
+		var result interface{} = group.row
+		if q.mapping != nil {
+			v, err := q.mapping(group)
+			if err != nil {
+				return nil, err
+			}
+			result = v
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// sort orders the groups by the ORDER BY clause, keeping the commit order
+// of equal ones.
+func (q *query) sort(groups []*scope) error {
+	if len(q.orderBy) == 0 {
+		return nil
+	}
+	keys := make([][]interface{}, len(groups))
+	for i, group := range groups {
+		for _, key := range q.orderBy {
+			v, err := key.expr(group)
+			if err != nil {
+				return err
+			}
+			keys[i] = append(keys[i], v)
+		}
+	}
+
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		for k, key := range q.orderBy {
+			a, b := keys[order[i]][k], keys[order[j]][k]
+			c, ok := compare(a, b)
+			if !ok {
+				c = sortRank(a) - sortRank(b)
+			}
+			if c != 0 {
+				return (c < 0) != key.desc
+			}
+		}
+		return false
+	})
+
+	sorted := make([]*scope, len(groups))
+	for i, j := range order {
+		sorted[i] = groups[j]
+	}
+	copy(groups, sorted)
+	return nil
+}
@@ -0,0 +1,100 @@
+package genesisdbtest
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+)
+
+func testRecords() []map[string]interface{} {
+	events := []genesisdb.Event{
+		{ID: "1", Subject: "/conference/2024/registrations/1", Type: "registration-created", Data: map[string]interface{}{"ticketType": "premium", "seats": 2, "name": "Ada"}},
+		{ID: "2", Subject: "/conference/2024/registrations/2", Type: "registration-created", Data: map[string]interface{}{"ticketType": "standard", "seats": 1, "name": "Bob"}},
+		{ID: "3", Subject: "/conference/2024/registrations/3", Type: "registration-created", Data: map[string]interface{}{"ticketType": "premium", "seats": 3, "name": "Cy"}},
+		{ID: "4", Subject: "/conference/2025/registrations/4", Type: "registration-created", Data: map[string]interface{}{"ticketType": "standard", "seats": 5, "name": "Di", "tags": []interface{}{"vip"}}},
+		{ID: "5", Subject: "/conference/2024/registrations/1", Type: "registration-cancelled", Data: map[string]interface{}{"reason": "it's late"}},
+	}
+	var records []map[string]interface{}
+	for _, event := range events {
+		records = append(records, record(event))
+	}
+	return records
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"All", "STREAM e FROM events MAP e.id", `["1","2","3","4","5"]`},
+		{"Where", "STREAM e FROM events WHERE e.data.seats > 1 AND e.type == 'registration-created' MAP e.id", `["1","3","4"]`},
+		{"Or and not", "STREAM e FROM events WHERE NOT (e.data.seats >= 2 || e.data.seats == null) MAP e.id", `["2"]`},
+		{"In", "STREAM e FROM events WHERE e.data.name IN ['Bob', 'Di'] MAP e.id", `["2","4"]`},
+		{"Not in", "STREAM e FROM events WHERE e.data.name NOT IN ['Bob', 'Di'] AND e.data.name != null MAP e.id", `["1","3"]`},
+		{"Under", "STREAM e FROM events WHERE e.subject UNDER '/conference/2024' MAP e.id", `["1","2","3","5"]`},
+		{"Between", "STREAM e FROM events WHERE e.data.seats BETWEEN 2 AND 3 MAP e.id", `["1","3"]`},
+		{"Escaped string", `STREAM e FROM events WHERE e.data.reason == 'it\'s late' MAP e.id`, `["5"]`},
+		{"Order and limit", "STREAM e FROM events WHERE e.data.seats != null ORDER BY e.data.seats DESC LIMIT 2 MAP e.data.name", `["Di","Cy"]`},
+		{"Order by several keys", "STREAM e FROM events WHERE e.data.seats != null ORDER BY e.data.ticketType, e.data.seats DESC MAP e.id", `["3","1","4","2"]`},
+		{"Object", "STREAM e FROM events WHERE e.id == '4' MAP { id: e.id, label: e.data.name + ' (' + e.data.seats + ')', first: e.data.tags[0], double: e.data.seats * 2 }",
+			`[{"double":10,"first":"vip","id":"4","label":"Di (5)"}]`},
+		{"Count", "STREAM e FROM events WHERE e.type == 'registration-created' MAP COUNT()", `[4]`},
+		{"Count of nothing", "STREAM e FROM events WHERE e.data.email == 'x' MAP COUNT() == 0", `[true]`},
+		{"Aggregates", "STREAM e FROM events MAP { sum: SUM(e.data.seats), avg: AVG(e.data.seats), min: MIN(e.data.seats), max: MAX(e.data.seats), count: COUNT(e.data.seats) }",
+			`[{"avg":2.75,"count":4,"max":5,"min":1,"sum":11}]`},
+		{"Sum with arithmetic", "STREAM e FROM events WHERE e.subject UNDER '/conference/2024' MAP SUM(e.data.seats) + 500 <= 506", `[true]`},
+		{"Group by", "STREAM e FROM events WHERE e.data.ticketType != null GROUP BY e.data.ticketType MAP { type: e.data.ticketType, seats: SUM(e.data.seats) }",
+			`[{"seats":5,"type":"premium"},{"seats":6,"type":"standard"}]`},
+		{"Having", "STREAM e FROM events WHERE e.subject UNDER '/conference' GROUP BY e.data.ticketType HAVING e.data.ticketType == 'premium' MAP COUNT() < 50", `[true]`},
+		{"Group limit", "STREAM e FROM events GROUP BY e.type ORDER BY COUNT() LIMIT 1 MAP e.type", `["registration-cancelled"]`},
+		{"Legacy syntax", "FROM e IN events WHERE e.data.seats > 2 ORDER BY e.id DESC TOP 1 PROJECT INTO { name: e.data.name }", `[{"name":"Di"}]`},
+		{"Whole event", "STREAM e FROM events WHERE e.id == '2' MAP e.data", `[{"name":"Bob","seats":1,"ticketType":"standard"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parseQuery() error = %v", err)
+			}
+			results, err := q.run(testRecords())
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if results == nil {
+				results = []interface{}{}
+			}
+			got, _ := json.Marshal(results)
+			if string(got) != tt.want {
+				t.Errorf("Results = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Empty", ""},
+		{"Unknown source", "STREAM e FROM things"},
+		{"Unbound parameter", "STREAM e FROM events WHERE e.id == $id"},
+		{"Unknown identifier", "STREAM e FROM events WHERE x.id == 1"},
+		{"Unknown function", "STREAM e FROM events MAP LENGTH(e.id)"},
+		{"Unterminated string", "STREAM e FROM events WHERE e.id == 'x"},
+		{"Trailing input", "STREAM e FROM events MAP e.id e.type"},
+		{"Invalid limit", "STREAM e FROM events LIMIT -1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseQuery(tt.query); !errors.Is(err, errQuery) {
+				t.Errorf("parseQuery() error = %v, want errQuery", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,534 @@
+// Package genesisdbtest provides an in-process fake of the GenesisDB API, so
+// code using the genesisdb client can be tested without a running server.
+//
+//	server := genesisdbtest.NewServer()
+//	defer server.Close()
+//
+//	client, _ := server.NewClient()
+//	client.CommitEvents(events)
+//
+// The fake keeps the committed events in memory. It implements the
+// commit, stream, observe, erase, query, ping and audit endpoints,
+// including preconditions, stream bounds and LatestByEventType. Queries and
+// isQueryResultTrue preconditions support a subset of GDBQL: WHERE with the
+// usual operators, IN, UNDER and BETWEEN, GROUP BY with HAVING, ORDER BY,
+// LIMIT, MAP with objects, arithmetic and the aggregate functions COUNT,
+// SUM, AVG, MIN and MAX.
+//
+// The fake follows the behavior of GenesisDB where it is documented. Where
+// it is not, it makes assumptions, so tests against the fake cannot catch a
+// server that behaves differently in these cases:
+//
+//   - A stream or observation whose lower or upper bound names an event
+//     that is not stored returns no events rather than an error. The client
+//     relies on this to find out whether a commit it retries was applied,
+//     see genesisdb.Genesisdb.EventExists.
+package genesisdbtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/google/uuid"
+)
+
+// AuthToken is the token the server accepts unless Server.AuthToken is
+// changed.
+const AuthToken = "genesisdbtest-token"
+
+// APIVersion is the API version the server serves.
+const APIVersion = "v1"
+
+// Server is a fake GenesisDB server.
+type Server struct {
+	*httptest.Server
+
+	// AuthToken is the bearer token requests must carry. Defaults to the
+	// AuthToken constant, an empty token accepts all requests.
+	AuthToken string
+
+	mu       sync.Mutex
+	events   []genesisdb.Event
+	records  []map[string]interface{}
+	ids      map[string]int
+	changed  chan struct{}
+	failures map[genesisdb.Operation][]int
+	requests map[genesisdb.Operation]int
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// NewServer starts a server that stores the given events.
+func NewServer(events ...genesisdb.Event) *Server {
+	s := &Server{
+		AuthToken: AuthToken,
+		ids:       make(map[string]int),
+		changed:   make(chan struct{}),
+		failures:  make(map[genesisdb.Operation][]int),
+		requests:  make(map[genesisdb.Operation]int),
+		closed:    make(chan struct{}),
+	}
+	s.Append(events...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/"+APIVersion+"/commit", s.handler(genesisdb.OperationCommit, http.MethodPost, s.commit))
+	mux.HandleFunc("/api/"+APIVersion+"/stream", s.handler(genesisdb.OperationStream, http.MethodPost, s.stream))
+	mux.HandleFunc("/api/"+APIVersion+"/observe", s.handler(genesisdb.OperationObserve, http.MethodPost, s.observe))
+	mux.HandleFunc("/api/"+APIVersion+"/erase", s.handler(genesisdb.OperationErase, http.MethodPost, s.erase))
+	mux.HandleFunc("/api/"+APIVersion+"/q", s.handler(genesisdb.OperationQuery, http.MethodPost, s.query))
+	mux.HandleFunc("/api/"+APIVersion+"/status/ping", s.handler(genesisdb.OperationPing, http.MethodGet, s.ping))
+	mux.HandleFunc("/api/"+APIVersion+"/status/audit", s.handler(genesisdb.OperationAudit, http.MethodGet, s.audit))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Close ends the running observations and shuts the server down.
+func (s *Server) Close() {
+	s.once.Do(func() { close(s.closed) })
+	s.Server.Close()
+}
+
+// Config returns a client configuration for the server.
+func (s *Server) Config() *genesisdb.Config {
+	return &genesisdb.Config{APIURL: s.URL, APIVersion: APIVersion, AuthToken: s.AuthToken}
+}
+
+// NewClient creates a client of the server.
+func (s *Server) NewClient(opts ...genesisdb.Option) (*genesisdb.Genesisdb, error) {
+	return genesisdb.NewClient(s.Config(), opts...)
+}
+
+// Events returns the stored events in the order they were committed.
+func (s *Server) Events() []genesisdb.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]genesisdb.Event(nil), s.events...)
+}
+
+// Append stores events as if they had been committed, without checking
+// them. Events without an ID or time get them.
+func (s *Server) Append(events ...genesisdb.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(events)
+}
+
+// FailNext answers the next requests to op with the given status codes,
+// one per request, before handling requests normally again.
+func (s *Server) FailNext(op genesisdb.Operation, statusCodes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[op] = append(s.failures[op], statusCodes...)
+}
+
+// Requests returns the number of requests made to op.
+func (s *Server) Requests(op genesisdb.Operation) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[op]
+}
+
+// handler wraps the handler of op with the checks common to all endpoints.
+func (s *Server) handler(op genesisdb.Operation, method string, handle func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[op]++
+		var failure int
+		if queued := s.failures[op]; len(queued) > 0 {
+			failure, s.failures[op] = queued[0], queued[1:]
+		}
+		s.mu.Unlock()
+
+		switch {
+		case r.Method != method:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		case s.AuthToken != "" && r.Header.Get("Authorization") != "Bearer "+s.AuthToken:
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+		case failure != 0:
+			writeError(w, failure, http.StatusText(failure))
+		default:
+			handle(w, r)
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// preconditionFailure is the shape in which failed preconditions are
+// reported with status 412.
+type preconditionFailure struct {
+	Index   int                    `json:"index"`
+	Type    string                 `json:"type"`
+	Payload map[string]interface{} `json:"payload"`
+	Reason  string                 `json:"reason"`
+}
+
+func (s *Server) commit(w http.ResponseWriter, r *http.Request) {
+	var req genesisdb.CommitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if len(req.Events) == 0 {
+		writeError(w, http.StatusBadRequest, "no events")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, event := range req.Events {
+		if !strings.HasPrefix(event.Subject, "/") || event.Type == "" {
+			writeError(w, http.StatusBadRequest, "events need a subject starting with / and a type")
+			return
+		}
+		if event.ID == "" {
+			continue
+		}
+		if _, ok := s.ids[event.ID]; ok || seen[event.ID] {
+			writeError(w, http.StatusConflict, "duplicate event ID "+event.ID)
+			return
+		}
+		seen[event.ID] = true
+	}
+
+	var failures []preconditionFailure
+	for i, precondition := range req.Preconditions {
+		reason, err := s.check(precondition)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if reason != "" {
+			failures = append(failures, preconditionFailure{Index: i, Type: precondition.Type, Payload: precondition.Payload, Reason: reason})
+		}
+	}
+	if len(failures) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": "precondition failed", "failedPreconditions": failures})
+		return
+	}
+
+	s.store(req.Events)
+	w.WriteHeader(http.StatusOK)
+}
+
+// check evaluates precondition and returns why it does not hold, or an
+// empty string if it does.
+func (s *Server) check(precondition genesisdb.Precondition) (string, error) {
+	switch precondition.Type {
+	case genesisdb.PreconditionIsSubjectNew, genesisdb.PreconditionIsSubjectExisting:
+		subject, _ := precondition.Payload["subject"].(string)
+		if subject == "" {
+			return "", fmt.Errorf("%s needs a subject", precondition.Type)
+		}
+		exists := false
+		for _, event := range s.events {
+			if event.Subject == subject {
+				exists = true
+				break
+			}
+		}
+		switch {
+		case exists && precondition.Type == genesisdb.PreconditionIsSubjectNew:
+			return "subject " + subject + " already has events", nil
+		case !exists && precondition.Type == genesisdb.PreconditionIsSubjectExisting:
+			return "subject " + subject + " has no events", nil
+		}
+		return "", nil
+	case genesisdb.PreconditionIsQueryResultTrue:
+		text, _ := precondition.Payload["query"].(string)
+		q, err := parseQuery(text)
+		if err != nil {
+			return "", err
+		}
+		results, err := q.run(s.records)
+		if err != nil {
+			return "", err
+		}
+		if len(results) == 0 {
+			return "query returned no result", nil
+		}
+		for _, result := range results {
+			if result != true {
+				return fmt.Sprintf("query returned %v", result), nil
+			}
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown precondition type %q", precondition.Type)
+}
+
+// store appends events to the log and wakes the observations. The caller
+// must hold the lock.
+func (s *Server) store(events []genesisdb.Event) {
+	for _, event := range events {
+		if event.ID == "" {
+			event.ID = uuid.New().String()
+		}
+		if event.SpecVersion == "" {
+			event.SpecVersion = "1.0"
+		}
+		if event.DataContentType == "" {
+			event.DataContentType = "application/json"
+		}
+		if event.Time == (genesisdb.RFC3339Time{}) {
+			event.Time = genesisdb.RFC3339Time(time.Now().UTC())
+		}
+		s.ids[event.ID] = len(s.events)
+		s.events = append(s.events, event)
+		s.records = append(s.records, record(event))
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// record returns the JSON form of event that queries run on.
+func record(event genesisdb.Event) map[string]interface{} {
+	data, _ := json.Marshal(event)
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// selection is the part of the log a stream or observation returns.
+type selection struct {
+	subject string
+	options genesisdb.StreamOptions
+}
+
+func newSelection(r *http.Request) (*selection, error) {
+	var req genesisdb.StreamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if err := req.Options.Validate(); err != nil {
+		return nil, err
+	}
+	sel := &selection{subject: req.Subject}
+	if req.Options != nil {
+		sel.options = *req.Options
+	}
+	if !strings.HasPrefix(sel.subject, "/") {
+		return nil, errors.New("subject must start with /")
+	}
+	return sel, nil
+}
+
+// window returns the positions [start, end) of the log within the bounds.
+// A bound that is not in the log selects nothing. This is an assumption
+// about the real server, see the package documentation. The caller must hold
+// the lock.
+func (s *Server) window(sel *selection) (int, int) {
+	start, end := 0, len(s.events)
+	if id := sel.options.LowerBound; id != "" {
+		i, ok := s.ids[id]
+		switch {
+		case !ok:
+			return end, end
+		case sel.options.IncludeLowerBoundEvent:
+			start = i
+		default:
+			start = i + 1
+		}
+	}
+	if id := sel.options.UpperBound; id != "" {
+		i, ok := s.ids[id]
+		switch {
+		case !ok:
+			return end, end
+		case sel.options.IncludeUpperBoundEvent:
+			end = i + 1
+		default:
+			end = i
+		}
+	}
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+// matches reports whether the event at position i belongs to the subject
+// and has the requested type. The caller must hold the lock.
+func (s *Server) matches(sel *selection, i int) bool {
+	event := s.events[i]
+	return under(event.Subject, sel.subject) &&
+		(sel.options.LatestByEventType == "" || event.Type == sel.options.LatestByEventType)
+}
+
+// selected returns the events of the log positions [start, end) of the
+// selection. With LatestByEventType only the latest event of every subject
+// is kept. The caller must hold the lock.
+func (s *Server) selected(sel *selection, start, end int) []genesisdb.Event {
+	var positions []int
+	latest := make(map[string]int)
+	for i := start; i < end; i++ {
+		if s.matches(sel, i) {
+			positions = append(positions, i)
+			latest[s.events[i].Subject] = i
+		}
+	}
+
+	var events []genesisdb.Event
+	for _, i := range positions {
+		if sel.options.LatestByEventType != "" && latest[s.events[i].Subject] != i {
+			continue
+		}
+		events = append(events, s.events[i])
+	}
+	return events
+}
+
+func writeLines(w http.ResponseWriter, values []interface{}) {
+	for _, value := range values {
+		line, _ := json.Marshal(value)
+		w.Write(append(line, '\n'))
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func writeEvents(w http.ResponseWriter, events []genesisdb.Event) {
+	values := make([]interface{}, len(events))
+	for i, event := range events {
+		values[i] = event
+	}
+	writeLines(w, values)
+}
+
+func (s *Server) stream(w http.ResponseWriter, r *http.Request) {
+	sel, err := newSelection(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	start, end := s.window(sel)
+	events := s.selected(sel, start, end)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	writeEvents(w, events)
+}
+
+// observe sends the selected events and then every matching event
+// committed later, until the client disconnects or, with an upper bound,
+// the bound has been sent.
+func (s *Server) observe(w http.ResponseWriter, r *http.Request) {
+	sel, err := newSelection(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	start, end := s.window(sel)
+	events := s.selected(sel, start, end)
+	changed := s.changed
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	writeEvents(w, events)
+	if sel.options.UpperBound != "" {
+		return
+	}
+
+	next := end
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closed:
+			return
+		case <-changed:
+		}
+
+		s.mu.Lock()
+		events = events[:0]
+		for ; next < len(s.events); next++ {
+			if s.matches(sel, next) {
+				events = append(events, s.events[next])
+			}
+		}
+		changed = s.changed
+		s.mu.Unlock()
+
+		writeEvents(w, events)
+	}
+}
+
+// erase removes the data of the events of subject that were committed with
+// StoreDataAsReference.
+func (s *Server) erase(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Subject string `json:"subject"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Subject == "" {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, event := range s.events {
+		if event.Subject != req.Subject || event.Options["storeDataAsReference"] != true {
+			continue
+		}
+		s.events[i].Data = nil
+		s.records[i] = record(s.events[i])
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	q, err := parseQuery(req.Query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	results, err := q.run(s.records)
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	writeLines(w, results)
+}
+
+func (s *Server) ping(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("pong"))
+}
+
+func (s *Server) audit(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	count := len(s.events)
+	s.mu.Unlock()
+	fmt.Fprintf(w, "audit ok: %d events", count)
+}
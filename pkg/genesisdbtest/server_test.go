@@ -0,0 +1,260 @@
+package genesisdbtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+)
+
+func newClient(t *testing.T, server *Server, opts ...genesisdb.Option) *genesisdb.Genesisdb {
+	t.Helper()
+	client, err := server.NewClient(opts...)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func event(subject, eventType string, data map[string]interface{}) genesisdb.Event {
+	return genesisdb.Event{Source: "io.genesisdb.test", Subject: subject, Type: eventType, Data: data}
+}
+
+func ids(events []genesisdb.Event) string {
+	var result []string
+	for _, e := range events {
+		result = append(result, e.Data.(map[string]interface{})["n"].(string))
+	}
+	return strings.Join(result, ",")
+}
+
+func TestServer_Stream(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := newClient(t, server)
+
+	err := client.CommitEvents([]genesisdb.Event{
+		event("/customer/1", "added", map[string]interface{}{"n": "a"}),
+		event("/customer/2", "added", map[string]interface{}{"n": "b"}),
+		event("/customer/1", "updated", map[string]interface{}{"n": "c"}),
+		event("/order/1", "placed", map[string]interface{}{"n": "d"}),
+		event("/customer/1", "updated", map[string]interface{}{"n": "e"}),
+		event("/customer/2", "updated", map[string]interface{}{"n": "f"}),
+	})
+	if err != nil {
+		t.Fatalf("CommitEvents() error = %v", err)
+	}
+	stored := server.Events()
+	if len(stored) != 6 || stored[0].ID == "" {
+		t.Fatalf("Stored events %+v", stored)
+	}
+
+	tests := []struct {
+		name    string
+		subject string
+		options *genesisdb.StreamOptions
+		want    string
+	}{
+		{"Subject", "/customer/1", nil, "a,c,e"},
+		{"Descendants", "/customer", nil, "a,b,c,e,f"},
+		{"Root", "/", nil, "a,b,c,d,e,f"},
+		{"Lower bound", "/customer", &genesisdb.StreamOptions{LowerBound: stored[1].ID}, "c,e,f"},
+		{"Lower bound included", "/customer", &genesisdb.StreamOptions{LowerBound: stored[1].ID, IncludeLowerBoundEvent: true}, "b,c,e,f"},
+		{"Upper bound", "/", &genesisdb.StreamOptions{UpperBound: stored[3].ID}, "a,b,c"},
+		{"Both bounds included", "/", &genesisdb.StreamOptions{LowerBound: stored[1].ID, IncludeLowerBoundEvent: true, UpperBound: stored[3].ID, IncludeUpperBoundEvent: true}, "b,c,d"},
+		{"Latest by event type", "/customer", &genesisdb.StreamOptions{LatestByEventType: "updated"}, "e,f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := client.StreamEvents(tt.subject, tt.options)
+			if err != nil {
+				t.Fatalf("StreamEvents() error = %v", err)
+			}
+			if got := ids(events); got != tt.want {
+				t.Errorf("StreamEvents() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Unknown bound", func(t *testing.T) {
+		events, err := client.StreamEvents("/", &genesisdb.StreamOptions{LowerBound: "missing"})
+		if err != nil || len(events) != 0 {
+			t.Errorf("StreamEvents() = %v, %v, want no events", events, err)
+		}
+	})
+}
+
+func TestServer_Preconditions(t *testing.T) {
+	server := NewServer(event("/user/1", "registered", map[string]interface{}{"email": "a@example.com"}))
+	defer server.Close()
+	client := newClient(t, server)
+
+	tests := []struct {
+		name         string
+		precondition genesisdb.Precondition
+		holds        bool
+	}{
+		{"Subject new", genesisdb.IsSubjectNew("/user/2"), true},
+		{"Subject not new", genesisdb.IsSubjectNew("/user/1"), false},
+		{"Subject existing", genesisdb.IsSubjectExisting("/user/1"), true},
+		{"Subject not existing", genesisdb.IsSubjectExisting("/user/3"), false},
+		{"Query true", genesisdb.IsQueryResultTrue("STREAM e FROM events WHERE e.data.email == 'b@example.com' MAP COUNT() == 0"), true},
+		{"Query false", genesisdb.IsQueryResultTrue("STREAM e FROM events WHERE e.data.email == 'a@example.com' MAP COUNT() == 0"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(server.Events())
+			err := client.CommitEventsWithPreconditions([]genesisdb.Event{event("/user/9", "noted", nil)}, []genesisdb.Precondition{tt.precondition})
+			if tt.holds {
+				if err != nil || len(server.Events()) != before+1 {
+					t.Errorf("CommitEventsWithPreconditions() error = %v, want commit", err)
+				}
+				return
+			}
+
+			var apiErr *genesisdb.APIError
+			if !errors.As(err, &apiErr) || !errors.Is(err, genesisdb.ErrPreconditionFailed) {
+				t.Fatalf("CommitEventsWithPreconditions() error = %v, want ErrPreconditionFailed", err)
+			}
+			if len(apiErr.FailedPreconditions) != 1 || apiErr.FailedPreconditions[0].Precondition.Type != tt.precondition.Type || apiErr.FailedPreconditions[0].Reason == "" {
+				t.Errorf("FailedPreconditions = %+v", apiErr.FailedPreconditions)
+			}
+			if len(server.Events()) != before {
+				t.Error("Events were stored despite failed precondition")
+			}
+		})
+	}
+
+	t.Run("Duplicate ID", func(t *testing.T) {
+		duplicate := event("/user/1", "noted", nil)
+		duplicate.ID = server.Events()[0].ID
+		err := client.CommitEvents([]genesisdb.Event{duplicate})
+		var apiErr *genesisdb.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != 409 {
+			t.Errorf("CommitEvents() error = %v, want status 409", err)
+		}
+	})
+}
+
+func TestServer_Observe(t *testing.T) {
+	server := NewServer(event("/order/1", "placed", map[string]interface{}{"n": "a"}))
+	defer server.Close()
+	client := newClient(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := client.Observe(ctx, "/order", nil)
+	defer sub.Close()
+
+	receive := func(want string) {
+		t.Helper()
+		select {
+		case e := <-sub.Events():
+			if got := e.Data.(map[string]interface{})["n"]; got != want {
+				t.Errorf("Received %v, want %s", got, want)
+			}
+		case err := <-sub.Errors():
+			t.Fatalf("Observe() error = %v", err)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for %s", want)
+		}
+	}
+
+	receive("a")
+	client.CommitEvents([]genesisdb.Event{
+		event("/customer/1", "added", map[string]interface{}{"n": "x"}),
+		event("/order/2", "placed", map[string]interface{}{"n": "b"}),
+	})
+	receive("b")
+	server.Append(event("/order/1", "shipped", map[string]interface{}{"n": "c"}))
+	receive("c")
+}
+
+func TestServer_Erase(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := newClient(t, server)
+
+	err := client.CommitEventsWithOptions([]genesisdb.Event{event("/user/456", "created", map[string]interface{}{"email": "john@example.com"})},
+		&genesisdb.CommitOptions{StoreDataAsReference: true})
+	if err != nil {
+		t.Fatalf("CommitEventsWithOptions() error = %v", err)
+	}
+	client.CommitEvents([]genesisdb.Event{event("/user/456", "noted", map[string]interface{}{"note": "kept"})})
+
+	if err := client.EraseData("/user/456"); err != nil {
+		t.Fatalf("EraseData() error = %v", err)
+	}
+	events, _ := client.StreamEvents("/user/456", nil)
+	if len(events) != 2 || events[0].Data != nil || events[1].Data == nil {
+		t.Errorf("Events after erase: %+v", events)
+	}
+}
+
+func TestServer_Query(t *testing.T) {
+	server := NewServer(
+		event("/customer/1", "added", map[string]interface{}{"firstName": "Ada"}),
+		event("/customer/2", "added", map[string]interface{}{"firstName": "Bob"}),
+	)
+	defer server.Close()
+	client := newClient(t, server)
+
+	results, err := client.Q("STREAM e FROM events WHERE e.type == $type ORDER BY e.data.firstName DESC MAP { name: e.data.firstName }", genesisdb.Params{"type": "added"})
+	if err != nil {
+		t.Fatalf("Q() error = %v", err)
+	}
+	if len(results) != 2 || results[0].(map[string]interface{})["name"] != "Bob" {
+		t.Errorf("Q() = %v", results)
+	}
+
+	type customer struct {
+		Name string `json:"name"`
+	}
+	customers, err := genesisdb.QueryAs[customer](client, "STREAM e FROM events MAP { name: e.data.firstName }")
+	if err != nil || len(customers) != 2 || customers[0].Name != "Ada" {
+		t.Errorf("QueryAs() = %v, %v", customers, err)
+	}
+
+	_, err = client.Q("STREAM e FROM events WHERE")
+	var apiErr *genesisdb.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 {
+		t.Errorf("Q() error = %v, want status 400", err)
+	}
+}
+
+func TestServer_Status(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := newClient(t, server)
+
+	if response, err := client.Ping(); err != nil || response != "pong" {
+		t.Errorf("Ping() = %q, %v", response, err)
+	}
+	if _, err := client.Audit(); err != nil {
+		t.Errorf("Audit() error = %v", err)
+	}
+
+	config := server.Config()
+	config.AuthToken = "wrong"
+	unauthorized, _ := genesisdb.NewClient(config)
+	if _, err := unauthorized.Ping(); !errors.Is(err, genesisdb.ErrUnauthorized) {
+		t.Errorf("Ping() error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestServer_FailNext(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := newClient(t, server, genesisdb.WithRetryPolicy(&genesisdb.RetryPolicy{InitialBackoff: time.Millisecond}))
+
+	server.FailNext(genesisdb.OperationCommit, 503)
+	if err := client.CommitEvents([]genesisdb.Event{event("/order/1", "placed", nil)}); err != nil {
+		t.Fatalf("CommitEvents() error = %v", err)
+	}
+	if len(server.Events()) != 1 || server.Requests(genesisdb.OperationCommit) != 2 {
+		t.Errorf("Stored %d events in %d commits, want 1 in 2", len(server.Events()), server.Requests(genesisdb.OperationCommit))
+	}
+}
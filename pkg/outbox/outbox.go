@@ -0,0 +1,297 @@
+// Package outbox commits events to GenesisDB reliably alongside writes to a
+// SQL database.
+//
+// Committing events after a database transaction loses them if the process
+// crashes in between. With an Outbox the events are instead stored in a
+// table of the same database, inside the transaction of the write they
+// belong to, and a Relay commits them to GenesisDB afterwards, in the order
+// they were added, retrying until it succeeds.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/google/uuid"
+)
+
+// DefaultTable is the name of the outbox table unless Outbox.Table is set.
+const DefaultTable = "genesisdb_outbox"
+
+// Status values of the rows of the outbox table.
+const (
+	StatusPending   = "pending"
+	StatusCommitted = "committed"
+	StatusRejected  = "rejected"
+)
+
+// Dialect covers the differences between the SQL databases an outbox can
+// use.
+type Dialect struct {
+	// Placeholder returns the placeholder of the n-th query parameter,
+	// counted from 1.
+	Placeholder func(n int) string
+	// Schema holds the statements creating the outbox table, with {table}
+	// standing for its name.
+	Schema []string
+}
+
+var (
+	// SQLite is the dialect of SQLite.
+	SQLite = Dialect{
+		Placeholder: dollarPlaceholder,
+		Schema: []string{
+			`CREATE TABLE IF NOT EXISTS {table} (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				events TEXT NOT NULL,
+				preconditions TEXT,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				last_error TEXT,
+				created_at TIMESTAMP NOT NULL,
+				processed_at TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS {table}_status ON {table} (status, id)`,
+		},
+	}
+	// Postgres is the dialect of PostgreSQL.
+	Postgres = Dialect{
+		Placeholder: dollarPlaceholder,
+		Schema: []string{
+			`CREATE TABLE IF NOT EXISTS {table} (
+				id BIGSERIAL PRIMARY KEY,
+				events TEXT NOT NULL,
+				preconditions TEXT,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				last_error TEXT,
+				created_at TIMESTAMPTZ NOT NULL,
+				processed_at TIMESTAMPTZ
+			)`,
+			`CREATE INDEX IF NOT EXISTS {table}_status ON {table} (status, id)`,
+		},
+	}
+	// MySQL is the dialect of MySQL and MariaDB.
+	MySQL = Dialect{
+		Placeholder: func(int) string { return "?" },
+		Schema: []string{
+			`CREATE TABLE IF NOT EXISTS {table} (
+				id BIGINT AUTO_INCREMENT PRIMARY KEY,
+				events LONGTEXT NOT NULL,
+				preconditions LONGTEXT,
+				status VARCHAR(16) NOT NULL DEFAULT 'pending',
+				attempts INT NOT NULL DEFAULT 0,
+				last_error TEXT,
+				created_at DATETIME(6) NOT NULL,
+				processed_at DATETIME(6),
+				INDEX (status, id)
+			)`,
+		},
+	}
+)
+
+func dollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Execer runs statements, it is implemented by *sql.Tx, *sql.DB and
+// *sql.Conn.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Entry is a batch of events added to the outbox together, which the relay
+// commits together.
+type Entry struct {
+	ID            int64
+	Events        []genesisdb.Event
+	Preconditions []genesisdb.Precondition
+	// Attempts counts the commits tried so far.
+	Attempts int
+}
+
+// Outbox stores events in a table of a SQL database until a Relay has
+// committed them.
+type Outbox struct {
+	// Table is the name of the outbox table. Defaults to DefaultTable.
+	Table string
+
+	db      *sql.DB
+	dialect Dialect
+}
+
+// New creates an outbox in db, which is accessed with dialect.
+func New(db *sql.DB, dialect Dialect) *Outbox {
+	return &Outbox{db: db, dialect: dialect}
+}
+
+// CreateTable creates the outbox table unless it exists.
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	for _, statement := range o.dialect.Schema {
+		if _, err := o.db.ExecContext(ctx, o.sql(statement)); err != nil {
+			return fmt.Errorf("error creating outbox table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Add stores events in the outbox, to be committed together with
+// preconditions. Pass the transaction of the write the events belong to, so
+// they are stored if and only if the transaction commits:
+//
+//	tx, _ := db.BeginTx(ctx, nil)
+//	tx.ExecContext(ctx, "UPDATE accounts SET balance = balance - 10 WHERE id = 1")
+//	ob.Add(ctx, tx, []genesisdb.Event{withdrawn})
+//	tx.Commit()
+//
+// Events without an ID or time get them now, so the relay can tell whether
+// an earlier attempt already committed them and the events carry the time
+// of the write rather than of the commit.
+func (o *Outbox) Add(ctx context.Context, tx Execer, events []genesisdb.Event, preconditions ...genesisdb.Precondition) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for _, precondition := range preconditions {
+		if err := precondition.Validate(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	events = append([]genesisdb.Event(nil), events...)
+	for i := range events {
+		if events[i].ID == "" {
+			events[i].ID = uuid.New().String()
+		}
+		if events[i].Time == (genesisdb.RFC3339Time{}) {
+			events[i].Time = genesisdb.RFC3339Time(now)
+		}
+	}
+
+	eventsJSON, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("error encoding events: %w", err)
+	}
+	var preconditionsJSON []byte
+	if len(preconditions) > 0 {
+		if preconditionsJSON, err = json.Marshal(preconditions); err != nil {
+			return fmt.Errorf("error encoding preconditions: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, o.sql("INSERT INTO {table} (events, preconditions, status, created_at) VALUES ({1}, {2}, {3}, {4})"),
+		string(eventsJSON), nullString(preconditionsJSON), StatusPending, now)
+	if err != nil {
+		return fmt.Errorf("error adding events to outbox: %w", err)
+	}
+	return nil
+}
+
+// Pending returns the number of entries not yet committed.
+func (o *Outbox) Pending(ctx context.Context) (int, error) {
+	var count int
+	err := o.db.QueryRowContext(ctx, o.sql("SELECT COUNT(*) FROM {table} WHERE status = {1}"), StatusPending).Scan(&count)
+	return count, err
+}
+
+// DeleteProcessed removes the entries committed or rejected before the
+// given time and returns how many there were.
+func (o *Outbox) DeleteProcessed(ctx context.Context, before time.Time) (int64, error) {
+	result, err := o.db.ExecContext(ctx, o.sql("DELETE FROM {table} WHERE status <> {1} AND processed_at < {2}"), StatusPending, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// pending returns the oldest limit entries not yet committed.
+func (o *Outbox) pending(ctx context.Context, limit int) ([]Entry, error) {
+	rows, err := o.db.QueryContext(ctx, o.sql("SELECT id, events, preconditions, attempts FROM {table} WHERE status = {1} ORDER BY id LIMIT "+strconv.Itoa(limit)), StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var (
+			entry         Entry
+			events        string
+			preconditions sql.NullString
+		)
+		if err := rows.Scan(&entry.ID, &events, &preconditions, &entry.Attempts); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(events), &entry.Events); err != nil {
+			return nil, fmt.Errorf("error decoding events of outbox entry %d: %w", entry.ID, err)
+		}
+		if preconditions.Valid {
+			if err := json.Unmarshal([]byte(preconditions.String), &entry.Preconditions); err != nil {
+				return nil, fmt.Errorf("error decoding preconditions of outbox entry %d: %w", entry.ID, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// attempt counts a commit attempt of the entry before it is tried.
+func (o *Outbox) attempt(ctx context.Context, id int64) error {
+	_, err := o.db.ExecContext(ctx, o.sql("UPDATE {table} SET attempts = attempts + 1 WHERE id = {1}"), id)
+	return err
+}
+
+// fail records the error of the last attempt. A status other than pending
+// stops further attempts.
+func (o *Outbox) fail(ctx context.Context, id int64, status string, cause error) error {
+	var processed interface{}
+	if status != StatusPending {
+		processed = time.Now().UTC()
+	}
+	_, err := o.db.ExecContext(ctx, o.sql("UPDATE {table} SET status = {1}, last_error = {2}, processed_at = {3} WHERE id = {4}"),
+		status, cause.Error(), processed, id)
+	return err
+}
+
+func (o *Outbox) done(ctx context.Context, id int64) error {
+	_, err := o.db.ExecContext(ctx, o.sql("UPDATE {table} SET status = {1}, last_error = NULL, processed_at = {2} WHERE id = {3}"),
+		StatusCommitted, time.Now().UTC(), id)
+	return err
+}
+
+// sql fills in the table name and the placeholders {1}, {2}, ... of query.
+func (o *Outbox) sql(query string) string {
+	table := o.Table
+	if table == "" {
+		table = DefaultTable
+	}
+	query = strings.ReplaceAll(query, "{table}", table)
+	for n := 1; strings.Contains(query, "{"+strconv.Itoa(n)+"}"); n++ {
+		query = strings.ReplaceAll(query, "{"+strconv.Itoa(n)+"}", o.dialect.Placeholder(n))
+	}
+	return query
+}
+
+func nullString(data []byte) sql.NullString {
+	return sql.NullString{String: string(data), Valid: data != nil}
+}
+
+// rejected reports whether err is a final answer of GenesisDB to a commit,
+// which another attempt would receive as well.
+func rejected(err error) bool {
+	var validationErr *genesisdb.ValidationError
+	if errors.Is(err, genesisdb.ErrPreconditionFailed) ||
+		errors.Is(err, genesisdb.ErrInvalidPrecondition) ||
+		errors.As(err, &validationErr) {
+		return true
+	}
+	var apiErr *genesisdb.APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == 400 || apiErr.StatusCode == 422)
+}
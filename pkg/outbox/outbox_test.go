@@ -0,0 +1,263 @@
+//go:build cgo
+
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdbtest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// subjects returns the subjects of the events stored by server.
+func subjects(server *genesisdbtest.Server) []string {
+	var subjects []string
+	for _, event := range server.Events() {
+		subjects = append(subjects, event.Subject)
+	}
+	return subjects
+}
+
+func newClient(t *testing.T, server *genesisdbtest.Server, opts ...genesisdb.Option) *genesisdb.Genesisdb {
+	client, err := server.NewClient(opts...)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func newOutbox(t *testing.T) *Outbox {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("CREATE TABLE accounts (id INTEGER PRIMARY KEY, balance INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	ob := New(db, SQLite)
+	if err := ob.CreateTable(context.Background()); err != nil {
+		t.Fatalf("CreateTable() error = %v", err)
+	}
+	return ob
+}
+
+func opened(subject string) genesisdb.Event {
+	return genesisdb.Event{Subject: subject, Type: "io.genesisdb.bank.account-opened", Data: map[string]interface{}{"balance": 0}}
+}
+
+// add adds events in a transaction that also writes to the database.
+func add(t *testing.T, ob *Outbox, events []genesisdb.Event, preconditions ...genesisdb.Precondition) {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := ob.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO accounts (balance) VALUES (0)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ob.Add(ctx, tx, events, preconditions...); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pending(t *testing.T, ob *Outbox) int {
+	t.Helper()
+	count, err := ob.Pending(context.Background())
+	if err != nil {
+		t.Fatalf("Pending() error = %v", err)
+	}
+	return count
+}
+
+func TestOutbox_Add(t *testing.T) {
+	ob := newOutbox(t)
+	ctx := context.Background()
+
+	tx, _ := ob.db.BeginTx(ctx, nil)
+	if err := ob.Add(ctx, tx, []genesisdb.Event{opened("/accounts/1")}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	tx.Rollback()
+	if count := pending(t, ob); count != 0 {
+		t.Errorf("Pending() after rollback = %d, want 0", count)
+	}
+
+	event := opened("/accounts/1")
+	add(t, ob, []genesisdb.Event{event})
+	if event.ID != "" {
+		t.Error("Add() modified the events of the caller")
+	}
+	entries, _ := ob.pending(ctx, 10)
+	if len(entries) != 1 || entries[0].Events[0].ID == "" || entries[0].Events[0].Time.Time().IsZero() {
+		t.Errorf("Stored entries %+v, want event with ID and time", entries)
+	}
+
+	if err := ob.Add(ctx, ob.db, []genesisdb.Event{event}, genesisdb.Precondition{Type: "isSubjectNew"}); !errors.Is(err, genesisdb.ErrInvalidPrecondition) {
+		t.Errorf("Add() error = %v, want ErrInvalidPrecondition", err)
+	}
+}
+
+func TestRelay_Mock(t *testing.T) {
+	t.Run("Commits in order", func(t *testing.T) {
+		server := genesisdbtest.NewServer()
+		defer server.Close()
+		ob := newOutbox(t)
+
+		add(t, ob, []genesisdb.Event{opened("/accounts/1"), opened("/accounts/2")})
+		add(t, ob, []genesisdb.Event{opened("/accounts/3")}, genesisdb.IsSubjectNew("/accounts/3"))
+		relay := NewRelay(newClient(t, server), ob)
+		relay.BatchSize = 1
+
+		committed, err := relay.Drain(context.Background())
+		if err != nil || committed != 2 {
+			t.Fatalf("Drain() = %d, %v, want 2 entries", committed, err)
+		}
+		if got := strings.Join(subjects(server), ","); got != "/accounts/1,/accounts/2,/accounts/3" {
+			t.Errorf("Committed %s", got)
+		}
+		if count := pending(t, ob); count != 0 {
+			t.Errorf("Pending() = %d, want 0", count)
+		}
+
+		deleted, err := ob.DeleteProcessed(context.Background(), time.Now().Add(time.Minute))
+		if err != nil || deleted != 2 {
+			t.Errorf("DeleteProcessed() = %d, %v, want 2", deleted, err)
+		}
+	})
+
+	t.Run("Retries failed entries", func(t *testing.T) {
+		server := genesisdbtest.NewServer()
+		defer server.Close()
+		server.FailNext(genesisdb.OperationCommit, http.StatusServiceUnavailable)
+		ob := newOutbox(t)
+
+		add(t, ob, []genesisdb.Event{opened("/accounts/1")})
+		add(t, ob, []genesisdb.Event{opened("/accounts/2")})
+		var reported []error
+		relay := NewRelay(newClient(t, server), ob)
+		relay.OnError = func(_ Entry, err error) { reported = append(reported, err) }
+
+		if _, err := relay.Drain(context.Background()); !errors.Is(err, genesisdb.ErrServerUnavailable) {
+			t.Fatalf("Drain() error = %v, want ErrServerUnavailable", err)
+		}
+		if count := pending(t, ob); count != 2 || len(subjects(server)) != 0 || len(reported) != 1 {
+			t.Fatalf("Pending() = %d, committed %v, reported %v", count, subjects(server), reported)
+		}
+
+		if committed, err := relay.Drain(context.Background()); err != nil || committed != 2 {
+			t.Fatalf("Drain() = %d, %v, want 2 entries", committed, err)
+		}
+		if got := strings.Join(subjects(server), ","); got != "/accounts/1,/accounts/2" {
+			t.Errorf("Committed %s", got)
+		}
+	})
+
+	t.Run("Does not commit twice", func(t *testing.T) {
+		clients := map[string][]genesisdb.Option{
+			"Plain": nil,
+			// The lookup must see events the registry would skip
+			"Registry": {genesisdb.WithRegistry(genesisdb.NewRegistry(genesisdb.UnknownTypeSkip))},
+		}
+		for name, opts := range clients {
+			t.Run(name, func(t *testing.T) {
+				server := genesisdbtest.NewServer()
+				defer server.Close()
+				server.FailNext(genesisdb.OperationCommit, http.StatusServiceUnavailable)
+				ob := newOutbox(t)
+
+				add(t, ob, []genesisdb.Event{opened("/accounts/1")})
+				add(t, ob, []genesisdb.Event{opened("/accounts/2")})
+				relay := NewRelay(newClient(t, server, opts...), ob)
+
+				if _, err := relay.Drain(context.Background()); err == nil {
+					t.Fatal("Drain() succeeded despite failed commit")
+				}
+				// The failed attempt was applied after all, only its
+				// response was lost
+				entries, _ := ob.pending(context.Background(), 10)
+				server.Append(entries[0].Events...)
+
+				if committed, err := relay.Drain(context.Background()); err != nil || committed != 2 {
+					t.Fatalf("Drain() = %d, %v, want 2 entries", committed, err)
+				}
+				if got := strings.Join(subjects(server), ","); got != "/accounts/1,/accounts/2" || server.Requests(genesisdb.OperationCommit) != 2 {
+					t.Errorf("Committed %s in %d commits, want each once", got, server.Requests(genesisdb.OperationCommit))
+				}
+			})
+		}
+	})
+
+	t.Run("Skips rejected entries", func(t *testing.T) {
+		server := genesisdbtest.NewServer()
+		defer server.Close()
+		ob := newOutbox(t)
+
+		add(t, ob, []genesisdb.Event{opened("/accounts/1")})
+		add(t, ob, []genesisdb.Event{opened("/accounts/1")}, genesisdb.IsSubjectNew("/accounts/1"))
+		add(t, ob, []genesisdb.Event{opened("/accounts/2")})
+		var rejectedIDs []int64
+		relay := NewRelay(newClient(t, server), ob)
+		relay.OnError = func(entry Entry, err error) {
+			if errors.Is(err, genesisdb.ErrPreconditionFailed) {
+				rejectedIDs = append(rejectedIDs, entry.ID)
+			}
+		}
+
+		if committed, err := relay.Drain(context.Background()); err != nil || committed != 2 {
+			t.Fatalf("Drain() = %d, %v, want 2 entries", committed, err)
+		}
+		if got := strings.Join(subjects(server), ","); got != "/accounts/1,/accounts/2" {
+			t.Errorf("Committed %s", got)
+		}
+		var status, lastError string
+		ob.db.QueryRow("SELECT status, last_error FROM genesisdb_outbox WHERE id = 2").Scan(&status, &lastError)
+		if len(rejectedIDs) != 1 || rejectedIDs[0] != 2 || status != StatusRejected || lastError == "" {
+			t.Errorf("Rejected %v, status %s, last error %q", rejectedIDs, status, lastError)
+		}
+	})
+
+	t.Run("Run relays on notify", func(t *testing.T) {
+		server := genesisdbtest.NewServer()
+		defer server.Close()
+		ob := newOutbox(t)
+		ob.Table = "events_outbox"
+		if err := ob.CreateTable(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		relay := NewRelay(newClient(t, server), ob)
+		relay.Interval = time.Hour
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- relay.Run(ctx) }()
+
+		add(t, ob, []genesisdb.Event{opened("/accounts/1")})
+		relay.Notify()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(subjects(server)) == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if len(subjects(server)) != 1 {
+			t.Error("Timeout waiting for relay")
+		}
+
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+)
+
+const (
+	defaultBatchSize = 100
+	defaultInterval  = time.Second
+	defaultBackoff   = time.Second
+	maxBackoff       = time.Minute
+)
+
+// Relay commits the entries of an outbox to GenesisDB. Entries are
+// committed one at a time in the order they were added. An entry that
+// fails is retried, and the entries after it wait, until it is committed
+// or GenesisDB rejects it for good, e.g. because a precondition failed, in
+// which case it is marked rejected and skipped.
+//
+// Run a single relay per outbox table, several relays would commit the
+// same entries.
+type Relay struct {
+	// BatchSize is the number of entries read from the table at once.
+	// Defaults to 100.
+	BatchSize int
+	// Interval is the delay between polls of an empty outbox. Defaults to
+	// one second, call Notify to relay new entries right away.
+	Interval time.Duration
+	// Backoff is the delay before retrying a failed entry. It doubles with
+	// every further failure, up to a minute. Defaults to one second.
+	Backoff time.Duration
+	// OnError, if set, is called with every failed commit of an entry, and
+	// with errors reading the outbox, in which case entry is empty.
+	OnError func(entry Entry, err error)
+
+	client *genesisdb.Genesisdb
+	outbox *Outbox
+	notify chan struct{}
+}
+
+// NewRelay creates a relay from outbox to client.
+func NewRelay(client *genesisdb.Genesisdb, outbox *Outbox) *Relay {
+	return &Relay{client: client, outbox: outbox, notify: make(chan struct{}, 1)}
+}
+
+// Notify wakes a running relay to relay new entries without waiting for
+// the next poll, call it after committing a transaction that added events.
+func (r *Relay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run relays entries until ctx is done and returns ctx.Err().
+func (r *Relay) Run(ctx context.Context) error {
+	failures := 0
+	for {
+		delay := r.Interval
+		if delay <= 0 {
+			delay = defaultInterval
+		}
+		if _, err := r.Drain(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failures++
+			delay = r.backoff(failures)
+		} else {
+			failures = 0
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-r.notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Drain commits the pending entries until the outbox is empty and returns
+// how many were committed. It stops with the error of the first entry that
+// could not be committed, which stays pending.
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	batchSize := r.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	committed := 0
+	for {
+		entries, err := r.outbox.pending(ctx, batchSize)
+		if err != nil {
+			err = fmt.Errorf("error reading outbox: %w", err)
+			r.report(Entry{}, err)
+			return committed, err
+		}
+		if len(entries) == 0 {
+			return committed, nil
+		}
+
+		for _, entry := range entries {
+			ok, err := r.relay(ctx, entry)
+			if err != nil {
+				return committed, err
+			}
+			if ok {
+				committed++
+			}
+		}
+	}
+}
+
+// relay commits entry and records the outcome. It reports false for an
+// entry that was rejected.
+func (r *Relay) relay(ctx context.Context, entry Entry) (bool, error) {
+	if err := r.outbox.attempt(ctx, entry.ID); err != nil {
+		return false, fmt.Errorf("error updating outbox entry %d: %w", entry.ID, err)
+	}
+
+	// An earlier attempt may have committed the events before the process
+	// stopped, committing them again would duplicate them
+	var err error
+	committed := false
+	if entry.Attempts > 0 {
+		committed, err = r.committed(ctx, entry)
+	}
+	if err == nil && !committed {
+		err = r.client.CommitEventsWithPreconditionsContext(ctx, entry.Events, entry.Preconditions)
+	}
+	entry.Attempts++
+
+	if err != nil {
+		r.report(entry, err)
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if rejected(err) {
+			if err := r.outbox.fail(ctx, entry.ID, StatusRejected, err); err != nil {
+				return false, fmt.Errorf("error updating outbox entry %d: %w", entry.ID, err)
+			}
+			return false, nil
+		}
+		if err := r.outbox.fail(ctx, entry.ID, StatusPending, err); err != nil {
+			return false, fmt.Errorf("error updating outbox entry %d: %w", entry.ID, err)
+		}
+		return false, fmt.Errorf("error committing outbox entry %d: %w", entry.ID, err)
+	}
+
+	if err := r.outbox.done(ctx, entry.ID); err != nil {
+		return false, fmt.Errorf("error updating outbox entry %d: %w", entry.ID, err)
+	}
+	return true, nil
+}
+
+// committed reports whether the events of entry are stored already. Commits
+// are atomic, so it is enough to look up the first event by its ID.
+func (r *Relay) committed(ctx context.Context, entry Entry) (bool, error) {
+	first := entry.Events[0]
+	return r.client.EventExists(ctx, first.Subject, first.ID)
+}
+
+func (r *Relay) backoff(failures int) time.Duration {
+	delay := r.Backoff
+	if delay <= 0 {
+		delay = defaultBackoff
+	}
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+func (r *Relay) report(entry Entry, err error) {
+	if r.OnError != nil {
+		r.OnError(entry, err)
+	}
+}
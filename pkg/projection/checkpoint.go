@@ -0,0 +1,95 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CheckpointStore keeps the ID of the last event every projection has
+// handled.
+type CheckpointStore interface {
+	// Load returns the checkpoint of the named projection, or an empty
+	// string if it has none.
+	Load(ctx context.Context, name string) (string, error)
+	Save(ctx context.Context, name, eventID string) error
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory, so projections start
+// from the beginning after a restart.
+type MemoryCheckpointStore struct {
+	mu          sync.RWMutex
+	checkpoints map[string]string
+}
+
+// NewMemoryCheckpointStore creates an empty in-memory store.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]string)}
+}
+
+func (s *MemoryCheckpointStore) Load(_ context.Context, name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkpoints[name], nil
+}
+
+func (s *MemoryCheckpointStore) Save(_ context.Context, name, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[name] = eventID
+	return nil
+}
+
+// FileCheckpointStore keeps every checkpoint in a file in a directory.
+type FileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore creates a store that writes checkpoints to dir,
+// which is created on the first Save.
+func NewFileCheckpointStore(dir string) *FileCheckpointStore {
+	return &FileCheckpointStore{dir: dir}
+}
+
+func (s *FileCheckpointStore) path(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+".checkpoint")
+}
+
+func (s *FileCheckpointStore) Load(_ context.Context, name string) (string, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (s *FileCheckpointStore) Save(_ context.Context, name, eventID string) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a partial
+	// checkpoint behind
+	tmp, err := os.CreateTemp(s.dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(eventID + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(name))
+}
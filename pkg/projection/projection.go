@@ -0,0 +1,230 @@
+// Package projection builds read models from the events stored in GenesisDB.
+//
+// A Projection passes every event of a subject to the handler registered for
+// its type. It subscribes with Genesisdb.Subscribe, which catches up on the
+// stored events and then follows new ones, after the event recorded in its
+// CheckpointStore. The checkpoint is saved after an event has been
+// handled, so after a crash at most the last event is handled again: handlers
+// must be idempotent. It holds the ID of the event as received from the API,
+// see Event.ResumeID, so upcasters may change IDs or split events.
+package projection
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+)
+
+// Handler applies an event to a read model.
+type Handler func(ctx context.Context, event genesisdb.Event) error
+
+// ErrorPolicy decides what a projection does when a handler fails.
+type ErrorPolicy struct {
+	// Retries is the number of times a failing event is handled again before
+	// giving up on it.
+	Retries int
+	// Backoff is the delay before the first retry. It doubles with every
+	// further retry, up to a minute. Defaults to 100ms.
+	Backoff time.Duration
+	// Skip continues with the next event once the retries are used up.
+	// Otherwise the projection stops with a *HandlerError.
+	Skip bool
+}
+
+var (
+	// StopOnError stops the projection on the first failure.
+	StopOnError = ErrorPolicy{}
+	// SkipOnError leaves failing events out of the read model.
+	SkipOnError = ErrorPolicy{Skip: true}
+)
+
+// RetryOnError retries a failing event up to retries times and then stops
+// the projection.
+func RetryOnError(retries int, backoff time.Duration) ErrorPolicy {
+	return ErrorPolicy{Retries: retries, Backoff: backoff}
+}
+
+const (
+	defaultRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff     = time.Minute
+)
+
+func (p ErrorPolicy) backoff(retry int) time.Duration {
+	delay := p.Backoff
+	if delay <= 0 {
+		delay = defaultRetryBackoff
+	}
+	for i := 1; i < retry && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay
+}
+
+// HandlerError is returned by Run when a handler failed and its policy
+// stopped the projection. The checkpoint still points before the event.
+type HandlerError struct {
+	Event genesisdb.Event
+	Err   error
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("error handling event %s of type %s: %v", e.Event.ID, e.Event.Type, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+type registeredHandler struct {
+	handler Handler
+	policy  ErrorPolicy
+}
+
+// Projection feeds the events of a subject to handlers.
+type Projection struct {
+	// Reconnect controls how the observation reconnects after the
+	// connection dropped. Nil uses the defaults of ReconnectPolicy.
+	Reconnect *genesisdb.ReconnectPolicy
+	// OnError, if set, is called with every handler failure, and with
+	// errors reported by the observation such as unparsable events, in
+	// which case event is empty.
+	OnError func(event genesisdb.Event, err error)
+	// OnCaughtUp, if set, is called once the events stored when Run started
+	// have been handled, i.e. the read model is current.
+	OnCaughtUp func()
+
+	client      *genesisdb.Genesisdb
+	name        string
+	subject     string
+	checkpoints CheckpointStore
+	handlers    map[string]registeredHandler
+}
+
+// New creates a projection of the events of subject that keeps its
+// checkpoint in checkpoints under name, which must be unique among the
+// projections sharing the store.
+func New(client *genesisdb.Genesisdb, name, subject string, checkpoints CheckpointStore) *Projection {
+	return &Projection{
+		client:      client,
+		name:        name,
+		subject:     subject,
+		checkpoints: checkpoints,
+		handlers:    make(map[string]registeredHandler),
+	}
+}
+
+// On registers the handler for events of eventType and what to do when it
+// fails. Events without a handler are passed over.
+func (p *Projection) On(eventType string, handler Handler, policy ErrorPolicy) *Projection {
+	p.handlers[eventType] = registeredHandler{handler: handler, policy: policy}
+	return p
+}
+
+// Handle registers a handler for events of eventType that also receives
+// their data decoded into E.
+func Handle[E any](p *Projection, eventType string, handler func(ctx context.Context, event genesisdb.Event, data E) error, policy ErrorPolicy) {
+	p.On(eventType, func(ctx context.Context, event genesisdb.Event) error {
+		if data, ok := event.Data.(E); ok {
+			return handler(ctx, event, data)
+		}
+		data, err := genesisdb.DecodeData[E](event)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, event, data)
+	}, policy)
+}
+
+// Run catches up on the stored events after the checkpoint and then follows
+// new events until ctx is done, a handler stops the projection or the
+// observation fails for good. It returns the reason it stopped, ctx.Err()
+// on cancellation.
+func (p *Projection) Run(ctx context.Context) error {
+	last, err := p.checkpoints.Load(ctx, p.name)
+	if err != nil {
+		return fmt.Errorf("error loading checkpoint: %w", err)
+	}
+
+	sub := p.client.Subscribe(ctx, p.subject, last, p.Reconnect)
+	defer sub.Close()
+
+	events, errs, caughtUp := sub.Events(), sub.Errors(), sub.CaughtUp()
+	for events != nil || errs != nil {
+		select {
+		case <-caughtUp:
+			caughtUp = nil
+			if p.OnCaughtUp != nil {
+				p.OnCaughtUp()
+			}
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if err := p.process(ctx, event); err != nil {
+				return err
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if p.OnError != nil {
+				p.OnError(genesisdb.Event{}, err)
+			}
+		}
+	}
+	return sub.Err()
+}
+
+// process handles event and then moves the checkpoint past it, unless it
+// is part of an event split by upcasters whose other parts are still to
+// come.
+func (p *Projection) process(ctx context.Context, event genesisdb.Event) error {
+	if registered, ok := p.handlers[event.Type]; ok {
+		if err := p.handle(ctx, registered, event); err != nil {
+			return err
+		}
+	}
+
+	id := event.ResumeID()
+	if id == "" {
+		return nil
+	}
+	if err := p.checkpoints.Save(ctx, p.name, id); err != nil {
+		return fmt.Errorf("error saving checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (p *Projection) handle(ctx context.Context, registered registeredHandler, event genesisdb.Event) error {
+	for retry := 1; ; retry++ {
+		err := registered.handler(ctx, event)
+		if err == nil {
+			return nil
+		}
+		if p.OnError != nil {
+			p.OnError(event, err)
+		}
+
+		if retry > registered.policy.Retries {
+			if registered.policy.Skip {
+				return nil
+			}
+			return &HandlerError{Event: event, Err: err}
+		}
+
+		timer := time.NewTimer(registered.policy.backoff(retry))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
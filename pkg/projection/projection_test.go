@@ -0,0 +1,370 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdb"
+	"github.com/genesisdb-io/genesisdb-io-client-go/pkg/genesisdbtest"
+)
+
+// newServer returns a fake server storing events and a client for it.
+func newServer(t *testing.T, events ...genesisdb.Event) (*genesisdbtest.Server, *genesisdb.Genesisdb) {
+	server := genesisdbtest.NewServer(events...)
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return server, client
+}
+
+func testEvent(id int) genesisdb.Event {
+	return genesisdb.Event{
+		ID:      fmt.Sprintf("e%d", id),
+		Subject: "/orders",
+		Type:    "order-placed",
+		Data:    map[string]interface{}{"total": id},
+	}
+}
+
+// collector records the IDs of handled events.
+type collector struct {
+	mu  sync.Mutex
+	ids []string
+	ch  chan string
+}
+
+func newCollector() *collector {
+	return &collector{ch: make(chan string, 100)}
+}
+
+func (c *collector) handle(_ context.Context, event genesisdb.Event) error {
+	c.mu.Lock()
+	c.ids = append(c.ids, event.ID)
+	c.mu.Unlock()
+	c.ch <- event.ID
+	return nil
+}
+
+func (c *collector) await(t *testing.T, ids ...string) {
+	t.Helper()
+	for _, want := range ids {
+		select {
+		case got := <-c.ch:
+			if got != want {
+				t.Fatalf("Handled %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for %s", want)
+		}
+	}
+}
+
+func TestProjection_Mock(t *testing.T) {
+	t.Run("Catch up then follow", func(t *testing.T) {
+		server, client := newServer(t, testEvent(1), testEvent(2), testEvent(3))
+		defer server.Close()
+
+		checkpoints := NewMemoryCheckpointStore()
+		orders := newCollector()
+		p := New(client, "orders", "/orders", checkpoints).On("order-placed", orders.handle, StopOnError)
+		caughtUp := make(chan struct{})
+		p.OnCaughtUp = func() { close(caughtUp) }
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- p.Run(ctx) }()
+
+		orders.await(t, "e1", "e2", "e3")
+		select {
+		case <-caughtUp:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for OnCaughtUp")
+		}
+		server.Append(testEvent(4))
+		orders.await(t, "e4")
+
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+		if checkpoint, _ := checkpoints.Load(ctx, "orders"); checkpoint != "e4" {
+			t.Errorf("Checkpoint = %s, want e4", checkpoint)
+		}
+		if stream, observe := server.Requests(genesisdb.OperationStream), server.Requests(genesisdb.OperationObserve); stream != 1 || observe != 1 {
+			t.Errorf("Made %d stream and %d observe requests, want 1 each", stream, observe)
+		}
+	})
+
+	t.Run("Resume from checkpoint", func(t *testing.T) {
+		server, client := newServer(t, testEvent(1), testEvent(2), testEvent(3))
+		defer server.Close()
+
+		checkpoints := NewFileCheckpointStore(t.TempDir())
+		checkpoints.Save(context.Background(), "orders", "e2")
+		orders := newCollector()
+		p := New(client, "orders", "/orders", checkpoints).On("order-placed", orders.handle, StopOnError)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go p.Run(ctx)
+
+		orders.await(t, "e3")
+		server.Append(testEvent(4))
+		orders.await(t, "e4")
+	})
+}
+
+func TestProjection_CaughtUpAfterHistory(t *testing.T) {
+	// More events than the subscription buffers, handled slowly
+	var history []genesisdb.Event
+	for i := 1; i <= 150; i++ {
+		history = append(history, testEvent(i))
+	}
+	server, client := newServer(t, history...)
+	defer server.Close()
+
+	handled := 0
+	p := New(client, "orders", "/orders", NewMemoryCheckpointStore()).On("order-placed", func(context.Context, genesisdb.Event) error {
+		handled++
+		time.Sleep(100 * time.Microsecond)
+		return nil
+	}, StopOnError)
+	caughtUp := make(chan int, 1)
+	p.OnCaughtUp = func() { caughtUp <- handled }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	select {
+	case n := <-caughtUp:
+		if n != len(history) {
+			t.Errorf("OnCaughtUp called after %d events, want %d", n, len(history))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for OnCaughtUp")
+	}
+}
+
+func TestProjection_SlowHandler(t *testing.T) {
+	// A handler call that takes longer than the legacy ObserveEvents send
+	// timeout, while more events than the subscription buffers are waiting
+	var history []genesisdb.Event
+	for i := 1; i <= 150; i++ {
+		history = append(history, testEvent(i))
+	}
+	server, client := newServer(t, history...)
+	defer server.Close()
+
+	handled := 0
+	p := New(client, "orders", "/orders", NewMemoryCheckpointStore()).On("order-placed", func(context.Context, genesisdb.Event) error {
+		handled++
+		if handled == 1 {
+			time.Sleep(6 * time.Second)
+		}
+		return nil
+	}, StopOnError)
+	caughtUp := make(chan int, 1)
+	p.OnCaughtUp = func() { caughtUp <- handled }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	select {
+	case n := <-caughtUp:
+		if n != len(history) {
+			t.Errorf("OnCaughtUp called after %d events, want %d", n, len(history))
+		}
+	case err := <-done:
+		t.Fatalf("Run() ended early: %v", err)
+	case <-time.After(15 * time.Second):
+		t.Fatal("Timeout waiting for OnCaughtUp")
+	}
+}
+
+func TestProjection_SplitEvents(t *testing.T) {
+	server := genesisdbtest.NewServer(testEvent(1), testEvent(2), testEvent(3))
+	defer server.Close()
+
+	// e2 is split into two events with made-up IDs
+	chain := genesisdb.NewUpcasterChain()
+	chain.SetVersionFunc(func(event genesisdb.Event) int {
+		if event.ID == "e2" {
+			return 1
+		}
+		return 2
+	})
+	chain.Register("order-placed", 1, func(event genesisdb.Event) ([]genesisdb.Event, error) {
+		first, second := event, event
+		first.ID, second.ID = "e2-a", "e2-b"
+		return []genesisdb.Event{first, second}, nil
+	})
+	client, _ := server.NewClient(genesisdb.WithUpcasters(chain))
+
+	failing := errors.New("read model unavailable")
+	checkpoints := NewMemoryCheckpointStore()
+	var checkpointed []string
+	last := make(chan struct{})
+	p := New(client, "orders", "/orders", checkpoints).On("order-placed", func(ctx context.Context, event genesisdb.Event) error {
+		checkpoint, _ := checkpoints.Load(ctx, "orders")
+		checkpointed = append(checkpointed, checkpoint)
+		switch {
+		case event.ID == "e2-b" && len(checkpointed) == 3:
+			return failing
+		case event.ID == "e3":
+			close(last)
+		}
+		return nil
+	}, StopOnError)
+
+	// The checkpoint does not move past e2 until both its parts are handled
+	if err := p.Run(context.Background()); !errors.Is(err, failing) {
+		t.Fatalf("Run() error = %v, want handler error", err)
+	}
+	if checkpoint, _ := checkpoints.Load(context.Background(), "orders"); checkpoint != "e1" {
+		t.Errorf("Checkpoint = %s, want e1", checkpoint)
+	}
+
+	// The restart resumes after the received ID, not a made-up one
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+	select {
+	case <-last:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for e3")
+	}
+	cancel()
+	<-done
+
+	want := "[ e1 e1 e1 e1 e2]"
+	if got := fmt.Sprint(checkpointed); got != want {
+		t.Errorf("Checkpoints seen by the handler = %s, want %s", got, want)
+	}
+	if checkpoint, _ := checkpoints.Load(context.Background(), "orders"); checkpoint != "e3" {
+		t.Errorf("Checkpoint = %s, want e3", checkpoint)
+	}
+}
+
+func TestProjection_ErrorPolicies(t *testing.T) {
+	failing := errors.New("read model unavailable")
+
+	t.Run("Stop", func(t *testing.T) {
+		server, client := newServer(t, testEvent(1), testEvent(2))
+		defer server.Close()
+
+		checkpoints := NewMemoryCheckpointStore()
+		p := New(client, "orders", "/orders", checkpoints).On("order-placed", func(_ context.Context, event genesisdb.Event) error {
+			if event.ID == "e2" {
+				return failing
+			}
+			return nil
+		}, StopOnError)
+
+		err := p.Run(context.Background())
+		var handlerErr *HandlerError
+		if !errors.As(err, &handlerErr) || handlerErr.Event.ID != "e2" || !errors.Is(err, failing) {
+			t.Fatalf("Run() error = %v, want *HandlerError for e2", err)
+		}
+		if checkpoint, _ := checkpoints.Load(context.Background(), "orders"); checkpoint != "e1" {
+			t.Errorf("Checkpoint = %s, want e1", checkpoint)
+		}
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		server, client := newServer(t, testEvent(1), testEvent(2), testEvent(3))
+		defer server.Close()
+
+		orders := newCollector()
+		var reported []string
+		p := New(client, "orders", "/orders", NewMemoryCheckpointStore()).On("order-placed", func(ctx context.Context, event genesisdb.Event) error {
+			if event.ID == "e2" {
+				return failing
+			}
+			return orders.handle(ctx, event)
+		}, SkipOnError)
+		p.OnError = func(event genesisdb.Event, err error) {
+			reported = append(reported, event.ID)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go p.Run(ctx)
+
+		orders.await(t, "e1", "e3")
+		if len(reported) != 1 || reported[0] != "e2" {
+			t.Errorf("Reported failures %v, want [e2]", reported)
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		server, client := newServer(t, testEvent(1))
+		defer server.Close()
+
+		attempts := 0
+		orders := newCollector()
+		p := New(client, "orders", "/orders", NewMemoryCheckpointStore()).On("order-placed", func(ctx context.Context, event genesisdb.Event) error {
+			attempts++
+			if attempts < 3 {
+				return failing
+			}
+			return orders.handle(ctx, event)
+		}, RetryOnError(2, time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go p.Run(ctx)
+
+		orders.await(t, "e1")
+		if attempts != 3 {
+			t.Errorf("Attempts = %d, want 3", attempts)
+		}
+	})
+
+	t.Run("Retries exhausted", func(t *testing.T) {
+		server, client := newServer(t, testEvent(1))
+		defer server.Close()
+
+		p := New(client, "orders", "/orders", NewMemoryCheckpointStore()).On("order-placed", func(context.Context, genesisdb.Event) error {
+			return failing
+		}, RetryOnError(2, time.Millisecond))
+
+		if err := p.Run(context.Background()); !errors.Is(err, failing) {
+			t.Errorf("Run() error = %v, want handler error", err)
+		}
+	})
+}
+
+func TestHandle_Mock(t *testing.T) {
+	server, client := newServer(t, testEvent(7))
+	defer server.Close()
+
+	totals := make(chan float64, 1)
+	p := New(client, "orders", "/orders", NewMemoryCheckpointStore())
+	Handle(p, "order-placed", func(_ context.Context, _ genesisdb.Event, data struct {
+		Total float64 `json:"total"`
+	}) error {
+		totals <- data.Total
+		return nil
+	}, StopOnError)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	select {
+	case total := <-totals:
+		if total != 7 {
+			t.Errorf("Total = %v, want 7", total)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for event")
+	}
+}